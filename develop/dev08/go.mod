@@ -1,5 +1,3 @@
 module github.com/mortum5/wb-l2/dev08
 
 go 1.21.0
-
-require github.com/mitchellh/go-ps v1.0.0
@@ -0,0 +1,78 @@
+package wbsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+var errUnalias = errors.New("unalias: usage: unalias [-a] name [name ...]")
+
+// alias - встроенная команда alias [name[=value]...]: без аргументов
+// список всех алиасов, name=value задаёт алиас, name выводит его.
+// Алиасы подставляются при разборе строки, поэтому новый алиас
+// действует со следующей строки
+func (s *Shell) alias(args []string, std stdio) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(s.aliases))
+		for name := range s.aliases {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		args = names
+	}
+	failed := false
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if ok {
+			if name == "" || strings.ContainsAny(name, " \t\n|&;<>()$`\\\"'/=") {
+				fmt.Fprintf(std.err, "alias: '%v': invalid alias name\n", name)
+				failed = true
+				continue
+			}
+			s.aliases[name] = value
+			continue
+		}
+		value, ok = s.aliases[name]
+		if !ok {
+			fmt.Fprintf(std.err, "alias: %v: not found\n", name)
+			failed = true
+			continue
+		}
+		if err := printAlias(std.out, name, value); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// printAlias - алиас в виде команды alias, пригодной для повторного ввода
+func printAlias(w io.Writer, name, value string) error {
+	_, err := fmt.Fprintf(w, "alias %v='%v'\n", name, strings.ReplaceAll(value, "'", `'\''`))
+	return err
+}
+
+// unalias - встроенная команда unalias [-a] name...
+func (s *Shell) unalias(args []string) error {
+	if len(args) == 0 {
+		return errUnalias
+	}
+	if args[0] == "-a" {
+		clear(s.aliases)
+		return nil
+	}
+	var err error
+	for _, name := range args {
+		if _, ok := s.aliases[name]; !ok {
+			err = fmt.Errorf("unalias: %v: not found", name)
+			continue
+		}
+		delete(s.aliases, name)
+	}
+	return err
+}
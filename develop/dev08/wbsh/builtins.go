@@ -0,0 +1,335 @@
+package wbsh
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var errSource = errors.New("source: filename argument required")
+
+// Builtin - встроенная команда. Встраивающая программа добавляет свои
+// через Shell.Register. Run получает аргументы вместе с именем команды
+// и возвращает код возврата. ctx у каждой команды свой: он отменяется
+// по Ctrl-C, kill задания и при закрытии сеанса в режиме сервера
+type Builtin interface {
+	Name() string
+	Usage() string
+	Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+// BuiltinFunc - функция встроенной команды
+type BuiltinFunc func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+// NewBuiltin - встроенная команда из функции
+func NewBuiltin(name, usage string, fn BuiltinFunc) Builtin {
+	return &funcBuiltin{name: name, usage: usage, fn: fn}
+}
+
+// funcBuiltin - встроенная команда, созданная NewBuiltin
+type funcBuiltin struct {
+	name, usage string
+	fn          BuiltinFunc
+}
+
+func (b *funcBuiltin) Name() string  { return b.name }
+func (b *funcBuiltin) Usage() string { return b.usage }
+
+func (b *funcBuiltin) Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	return b.fn(ctx, args, stdin, stdout, stderr)
+}
+
+// Register - добавление встроенной команды, одноимённая заменяется
+func (s *Shell) Register(b Builtin) {
+	s.builtins[b.Name()] = b
+}
+
+// execKey - ключ контекста, под которым собственные команды шелла
+// получают сам шелл и полные потоки команды
+type execKey struct{}
+
+// execState - шелл и потоки выполняемой встроенной команды
+type execState struct {
+	sh  *Shell
+	std stdio
+}
+
+// builtinCmd - собственная встроенная команда шелла: ей нужен сам
+// шелл, поэтому run получает его из контекста вызова
+type builtinCmd struct {
+	name  string
+	usage string
+	help  string
+	run   func(s *Shell, args []string, std stdio) error
+}
+
+func (c *builtinCmd) Name() string  { return c.name }
+func (c *builtinCmd) Usage() string { return c.usage }
+
+// Help - подробное описание для help NAME
+func (c *builtinCmd) Help() string { return c.help }
+
+// Run - выполнение команды, ошибка выводится в stderr
+func (c *builtinCmd) Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	st, ok := ctx.Value(execKey{}).(*execState)
+	if !ok {
+		fmt.Fprintf(stderr, "%v: can only be run by the shell\n", c.name)
+		return 2
+	}
+	err := c.run(st.sh, args, st.std)
+	var code exitCode
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &code):
+		return int(code)
+	case errors.Is(err, syscall.EPIPE):
+		// читатель канала закрылся: молча, как процесс, убитый SIGPIPE
+		return 128 + int(syscall.SIGPIPE)
+	}
+	fmt.Fprintln(stderr, err)
+	return 1
+}
+
+// builtin - выполнение встроенной команды args[0]
+func (s *Shell) builtin(args []string, std stdio) int {
+	b, ok := s.builtins[args[0]]
+	if !ok {
+		fmt.Fprintf(std.err, "unknown command '%v'\n", args[0])
+		return 127
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	if std.job != nil {
+		std.job.builtinStarted()
+		stop := context.AfterFunc(std.job.ctx, cancel)
+		defer stop()
+	}
+	ctx = context.WithValue(ctx, execKey{}, &execState{sh: s, std: std})
+	stdin := std.in
+	if stdin == nil {
+		stdin = strings.NewReader("")
+	}
+	return b.Run(ctx, args, stdin, std.out, std.err)
+}
+
+// defaultBuiltins - встроенные команды каждого шелла, остальные
+// ищутся в $PATH
+var defaultBuiltins = []*builtinCmd{
+	{"cd", "cd [dir | -]", "Change the current directory to dir, $HOME by default, or to $OLDPWD with -.",
+		func(s *Shell, args []string, std stdio) error { return s.cd(args[1:], std.out) }},
+	{"pwd", "pwd", "Print the current working directory.",
+		func(s *Shell, args []string, std stdio) error {
+			if len(args) != 1 {
+				return errPwd
+			}
+			return s.pwd(std.out)
+		}},
+	{"pushd", "pushd [dir | +N | -N]", "Push dir onto the directory stack and change to it; without dir swap the top two entries.",
+		func(s *Shell, args []string, std stdio) error { return s.pushd(args[1:], std.out) }},
+	{"popd", "popd [+N | -N]", "Remove the top (or N-th) entry from the directory stack and change to the new top.",
+		func(s *Shell, args []string, std stdio) error { return s.popd(args[1:], std.out) }},
+	{"dirs", "dirs [-clpv]", "Display the directory stack; -c clears it.",
+		func(s *Shell, args []string, std stdio) error { return s.dirs(args[1:], std.out) }},
+	{"echo", "echo [-n] [arg ...]", "Write arguments to the standard output; -n omits the trailing newline.",
+		func(s *Shell, args []string, std stdio) error { return s.echo(std.out, args[1:]) }},
+	{"ps", "ps [-ef] [-o format] [--sort keys] [--forest]", "Report a snapshot of the current processes.",
+		func(s *Shell, args []string, std stdio) error { return s.ps(args[1:], std.out) }},
+	{"kill", "kill [-s sig | -sig] pid | %job ... or kill -l [sig]", "Send a signal to processes or jobs.",
+		func(s *Shell, args []string, std stdio) error { return s.kill(args[1:], std) }},
+	{"jobs", "jobs", "List active jobs.",
+		func(s *Shell, args []string, std stdio) error { return s.jobsCmd(std.out) }},
+	{"fg", "fg [%job]", "Move a job to the foreground.",
+		func(s *Shell, args []string, std stdio) error { return s.fg(args[1:], std) }},
+	{"bg", "bg [%job]", "Resume a stopped job in the background.",
+		func(s *Shell, args []string, std stdio) error { return s.bg(args[1:], std) }},
+	{"wait", "wait [pid | %job ...]", "Wait for background jobs to finish.",
+		func(s *Shell, args []string, std stdio) error { return s.wait(args[1:]) }},
+	{"export", "export [name[=value] ...]", "Mark variables for export to child processes.",
+		func(s *Shell, args []string, std stdio) error { return s.export(args[1:], std.out) }},
+	{"unset", "unset name ...", "Remove variables.",
+		func(s *Shell, args []string, std stdio) error { return s.unset(args[1:]) }},
+	{"env", "env", "Print exported variables.",
+		func(s *Shell, args []string, std stdio) error { return s.env(std.out) }},
+	{"set", "set [-ex] [+ex] [--] [arg ...]", "Set shell options and positional parameters.",
+		func(s *Shell, args []string, std stdio) error { return s.set(args[1:], std.out) }},
+	{"shift", "shift [n]", "Shift positional parameters to the left by n, 1 by default.",
+		func(s *Shell, args []string, std stdio) error { return s.shift(args[1:]) }},
+	{"local", "local name[=value] ...", "Define variables local to the current function.",
+		func(s *Shell, args []string, std stdio) error { return s.local(args[1:]) }},
+	{"return", "return [n]", "Return from a function or a sourced file.",
+		func(s *Shell, args []string, std stdio) error { return s.returnCmd(args[1:], std.err) }},
+	{"break", "break [n]", "Exit from n enclosing loops.",
+		func(s *Shell, args []string, std stdio) error { return s.loopCtl(args[0], args[1:]) }},
+	{"continue", "continue [n]", "Resume the next iteration of the n-th enclosing loop.",
+		func(s *Shell, args []string, std stdio) error { return s.loopCtl(args[0], args[1:]) }},
+	{"alias", "alias [name[=value] ...]", "Define or display aliases.",
+		func(s *Shell, args []string, std stdio) error { return s.alias(args[1:], std) }},
+	{"unalias", "unalias [-a] name ...", "Remove aliases; -a removes all of them.",
+		func(s *Shell, args []string, std stdio) error { return s.unalias(args[1:]) }},
+	{"type", "type name ...", "Describe how each name would be interpreted as a command.",
+		func(s *Shell, args []string, std stdio) error { return s.typeCmd(args[1:], std) }},
+	{"which", "which [-a] name ...", "Locate commands in $PATH; -a prints all matches.",
+		func(s *Shell, args []string, std stdio) error { return s.which(args[1:], std) }},
+	{"source", "source file [arg ...]", "Execute commands from file in the current shell.",
+		func(s *Shell, args []string, std stdio) error { return s.source(args[1:]) }},
+	{".", ". file [arg ...]", "Execute commands from file in the current shell.",
+		func(s *Shell, args []string, std stdio) error { return s.source(args[1:]) }},
+	{":", ": [arg ...]", "Do nothing and succeed; arguments are still expanded.",
+		func(s *Shell, args []string, std stdio) error { return nil }},
+	{"true", "true", "Return a successful status.",
+		func(s *Shell, args []string, std stdio) error { return nil }},
+	{"false", "false", "Return an unsuccessful status.",
+		func(s *Shell, args []string, std stdio) error { return exitCode(1) }},
+	{"exit", "exit [n]", "Exit the shell with status n, the last status by default. \\quit works too.",
+		func(s *Shell, args []string, std stdio) error { return s.exit(args[1:], std.err) }},
+	{"help", "help [name ...]", "Display information about builtin commands.",
+		func(s *Shell, args []string, std stdio) error { return s.help(args[1:], std.out) }},
+}
+
+// keywords - зарезервированные слова для type
+var keywords = []string{
+	"!", "{", "}", "if", "then", "elif", "else", "fi",
+	"while", "until", "for", "in", "do", "done", "case", "esac",
+}
+
+// typeCmd - встроенная команда type: чем является каждое имя
+func (s *Shell) typeCmd(args []string, std stdio) error {
+	failed := false
+	for _, name := range args {
+		var err error
+		if value, ok := s.aliases[name]; ok {
+			_, err = fmt.Fprintf(std.out, "%v is aliased to `%v'\n", name, value)
+		} else if slices.Contains(keywords, name) {
+			_, err = fmt.Fprintf(std.out, "%v is a shell keyword\n", name)
+		} else if _, ok := s.funcs[name]; ok {
+			_, err = fmt.Fprintf(std.out, "%v is a function\n", name)
+		} else if _, ok := s.builtins[name]; ok {
+			_, err = fmt.Fprintf(std.out, "%v is a shell builtin\n", name)
+		} else if path, lookErr := s.LookPath(name); lookErr == nil {
+			_, err = fmt.Fprintf(std.out, "%v is %v\n", name, path)
+		} else {
+			fmt.Fprintf(std.err, "type: %v: not found\n", name)
+			failed = true
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if failed {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// which - встроенная команда which [-a]: пути к командам из $PATH
+func (s *Shell) which(args []string, std stdio) error {
+	all := len(args) > 0 && args[0] == "-a"
+	if all {
+		args = args[1:]
+	}
+	failed := false
+	for _, name := range args {
+		paths := s.searchPath(name, all)
+		if len(paths) == 0 {
+			path, _ := s.Get("PATH")
+			fmt.Fprintf(std.err, "which: no %v in (%v)\n", name, path)
+			failed = true
+		}
+		for _, path := range paths {
+			if _, err := fmt.Fprintln(std.out, path); err != nil {
+				return err
+			}
+		}
+	}
+	if failed {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// source - встроенная команда source (.): команды файла выполняются
+// в текущем шелле, аргументы на время становятся позиционными
+// параметрами. Код возврата - у последней команды или return
+func (s *Shell) source(args []string) error {
+	if len(args) == 0 {
+		return errSource
+	}
+	f, err := s.open(args[0], os.O_RDONLY)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer f.Close()
+	if len(args) > 1 {
+		saved := s.args
+		s.args = args[1:]
+		defer func() { s.args = saved }()
+	}
+
+	s.sourcing++
+	err = s.execute(&scanner{Scanner: bufio.NewScanner(f)}, false)
+	s.sourcing--
+	s.returning = false
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	return exitCode(s.Status)
+}
+
+// exit - встроенная команда exit [N]: завершение шелла (в подоболочке -
+// только её) с кодом N или кодом последней команды
+func (s *Shell) exit(args []string, errOut io.Writer) error {
+	status := s.Status
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(errOut, "exit: %v: numeric argument required\n", args[0])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	s.exited = true
+	return exitCode(status)
+}
+
+// help - встроенная команда help: список встроенных команд или
+// описание указанных. Подробный текст выводится, если у команды
+// есть метод Help() string
+func (s *Shell) help(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(s.builtins))
+		for name := range s.builtins {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		fmt.Fprintln(out, "Shell builtin commands. Type 'help name' to find out more about 'name'.")
+		for _, name := range names {
+			if _, err := fmt.Fprintf(out, "  %v\n", s.builtins[name].Usage()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range args {
+		b, ok := s.builtins[name]
+		if !ok {
+			return fmt.Errorf("help: no help topics match '%v'", name)
+		}
+		if _, err := fmt.Fprintf(out, "%v: %v\n", name, b.Usage()); err != nil {
+			return err
+		}
+		if h, ok := b.(interface{ Help() string }); ok {
+			if _, err := fmt.Fprintf(out, "    %v\n", h.Help()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
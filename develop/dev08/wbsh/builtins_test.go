@@ -0,0 +1,100 @@
+package wbsh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	var out strings.Builder
+	sh := NewShell(&out, strings.NewReader(""))
+	sh.Register(NewBuiltin("upper", "upper [word ...]", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		if len(args) == 1 {
+			data, _ := io.ReadAll(stdin)
+			fmt.Fprint(stdout, strings.ToUpper(string(data)))
+			return 0
+		}
+		if args[1] == "fail" {
+			fmt.Fprintln(stderr, "upper: failed")
+			return 3
+		}
+		fmt.Fprintln(stdout, strings.ToUpper(strings.Join(args[1:], " ")))
+		return 0
+	}))
+	sh.Register(NewBuiltin("pwd", "pwd", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		fmt.Fprintln(stdout, "overridden")
+		return 0
+	}))
+
+	input := "upper a b\necho x y | upper\nupper fail; echo $?\npwd\ntype upper\nhelp upper"
+	if status := sh.Script(strings.NewReader(input)); status != 0 {
+		t.Errorf("status: %v", status)
+	}
+	want := "A B\nX Y\nupper: failed\n3\noverridden\nupper is a shell builtin\nupper: upper [word ...]\n"
+	if out.String() != want {
+		t.Errorf("out: %q, want: %q", out.String(), want)
+	}
+}
+
+func TestBuiltinContext(t *testing.T) {
+	block := NewBuiltin("block", "block", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		<-ctx.Done()
+		fmt.Fprintln(stdout, "canceled")
+		return 130
+	})
+
+	t.Run("Background job", func(t *testing.T) {
+		var out strings.Builder
+		sh := NewShell(&out, strings.NewReader(""))
+		sh.Register(block)
+		sh.Script(strings.NewReader("block &\nkill -INT %1\nwait %1; echo $?"))
+		if want := "[1]\ncanceled\n130\n"; out.String() != want {
+			t.Errorf("out: %q, want: %q", out.String(), want)
+		}
+	})
+
+	t.Run("Foreground Ctrl-C", func(t *testing.T) {
+		var out syncBuilder
+		sh := NewShell(&out, strings.NewReader(""))
+		sh.Register(block)
+		done := make(chan int)
+		go func() { done <- sh.Script(strings.NewReader("block; echo $?")) }()
+		// как обработчик SIGINT интерактивного шелла
+		for sh.jobs.foreground() == nil {
+			time.Sleep(time.Millisecond)
+		}
+		sh.jobs.foreground().signal(syscall.SIGINT)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("builtin was not canceled")
+		}
+		if want := "canceled\n130\n"; out.String() != want {
+			t.Errorf("out: %q, want: %q", out.String(), want)
+		}
+	})
+}
+
+// syncBuilder - strings.Builder для записи из другой горутины
+type syncBuilder struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (b *syncBuilder) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuilder) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
@@ -0,0 +1,152 @@
+package wbsh
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// completeSpecial - символы, которые экранируются в дополненном слове
+const completeSpecial = " \t'\"\\$|&;<>()*?[]`"
+
+// commandStarts - слова, после которых снова идёт имя команды
+var commandStarts = map[string]bool{
+	"if": true, "then": true, "elif": true, "else": true, "while": true,
+	"until": true, "do": true, "!": true, "{": true,
+}
+
+// complete - дополнение слова в конце prefix: в позиции команды -
+// встроенные команды, функции и программы из $PATH, иначе пути
+// к файлам. Возвращает слово, как оно записано в строке, и варианты
+// замены для него
+func (s *Shell) complete(prefix string) (string, []string) {
+	start := 0
+	for i := 0; i < len(prefix); i++ {
+		switch {
+		case prefix[i] == '\\':
+			i++
+		case isMeta(prefix[i]):
+			start = i + 1
+		}
+	}
+	word := prefix[start:]
+	raw := unescape(word)
+
+	var cands []string
+	if isCommandPos(prefix[:start]) && !strings.Contains(raw, "/") {
+		cands = s.commandNames(raw)
+	} else {
+		cands = s.completePath(raw)
+	}
+	for i, c := range cands {
+		cands[i] = escape(c)
+	}
+	slices.Sort(cands)
+	return word, slices.Compact(cands)
+}
+
+// isCommandPos - слово после before будет именем команды
+func isCommandPos(before string) bool {
+	before = strings.TrimRight(before, " \t")
+	if before == "" || strings.ContainsRune("|&;(\n", rune(before[len(before)-1])) {
+		return true
+	}
+	fields := strings.Fields(before)
+	return commandStarts[fields[len(fields)-1]]
+}
+
+// commandNames - имена команд, начинающиеся с prefix
+func (s *Shell) commandNames(prefix string) []string {
+	var names []string
+	add := func(name string) {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	for name := range s.builtins {
+		add(name)
+	}
+	for name := range s.funcs {
+		add(name)
+	}
+	path, _ := s.Get("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		entries, err := os.ReadDir(s.path(dir))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), prefix) && executable(filepath.Join(s.path(dir), entry.Name())) == nil {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	return names
+}
+
+// completePath - пути, начинающиеся с prefix. Каталоги дополняются
+// слэшем, скрытые файлы предлагаются, только если prefix начинается с точки
+func (s *Shell) completePath(prefix string) []string {
+	dir, base := filepath.Split(prefix)
+	readDir := s.path(dir)
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		path := dir + name
+		if info, err := os.Stat(filepath.Join(readDir, name)); err == nil && info.IsDir() {
+			path += "/"
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// escape - экранирование спецсимволов обратным слэшем
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(completeSpecial, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// unescape - снятие экранирования обратным слэшем
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// newEditor - редактор строки для интерактивного шелла: история
+// в ~/.wbsh_history, дополнение команд и путей
+func (s *Shell) newEditor(f *os.File) *editor {
+	histFile := ""
+	if home, ok := s.Get("HOME"); ok && home != "" {
+		histFile = filepath.Join(home, ".wbsh_history")
+	}
+	e := newEditor(f, histFile)
+	e.complete = s.complete
+	return e
+}
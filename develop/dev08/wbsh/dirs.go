@@ -0,0 +1,168 @@
+package wbsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	errNoOtherDir = errors.New("pushd: no other directory")
+	errDirsEmpty  = errors.New("popd: directory stack empty")
+)
+
+// dirList - стек каталогов целиком: текущий каталог и dirStack
+func (s *Shell) dirList() []string {
+	return append([]string{s.dir}, s.dirStack...)
+}
+
+// stackIndex - номер элемента стека по +N (слева) или -N (справа)
+func (s *Shell) stackIndex(name, arg string) (int, error) {
+	n, err := strconv.Atoi(arg[1:])
+	size := len(s.dirStack) + 1
+	if err != nil || n < 0 || n >= size {
+		return 0, fmt.Errorf("%v: %v: directory stack index out of range", name, arg)
+	}
+	if arg[0] == '-' {
+		n = size - 1 - n
+	}
+	return n, nil
+}
+
+// isStackIndex - аргумент вида +N или -N
+func isStackIndex(arg string) bool {
+	if len(arg) < 2 || arg[0] != '+' && arg[0] != '-' {
+		return false
+	}
+	_, err := strconv.Atoi(arg[1:])
+	return err == nil
+}
+
+// pushd - встроенная команда pushd [каталог | +N | -N]: переход
+// в каталог с сохранением текущего в стеке. Без аргумента верхние
+// два каталога меняются местами, +N прокручивает стек к N-му
+func (s *Shell) pushd(args []string, out io.Writer) error {
+	list := s.dirList()
+	switch {
+	case len(args) > 1:
+		return errors.New("pushd: too many arguments")
+	case len(args) == 0:
+		if len(s.dirStack) == 0 {
+			return errNoOtherDir
+		}
+		list[0], list[1] = list[1], list[0]
+	case isStackIndex(args[0]):
+		n, err := s.stackIndex("pushd", args[0])
+		if err != nil {
+			return err
+		}
+		list = append(list[n:], list[:n]...)
+	default:
+		if err := s.chdir(args[0]); err != nil {
+			return fmt.Errorf("pushd: %w", err)
+		}
+		s.dirStack = list
+		return s.dirs(nil, out)
+	}
+	if err := s.chdir(list[0]); err != nil {
+		return fmt.Errorf("pushd: %w", err)
+	}
+	s.dirStack = list[1:]
+	return s.dirs(nil, out)
+}
+
+// popd - встроенная команда popd [+N | -N]: удаление верхнего каталога
+// стека и переход в следующий, +N удаляет N-й без перехода
+func (s *Shell) popd(args []string, out io.Writer) error {
+	if len(s.dirStack) == 0 {
+		return errDirsEmpty
+	}
+	n := 0
+	switch {
+	case len(args) > 1:
+		return errors.New("popd: too many arguments")
+	case len(args) == 1 && isStackIndex(args[0]):
+		var err error
+		if n, err = s.stackIndex("popd", args[0]); err != nil {
+			return err
+		}
+	case len(args) == 1:
+		return fmt.Errorf("popd: %v: invalid argument", args[0])
+	}
+	if n == 0 {
+		if err := s.chdir(s.dirStack[0]); err != nil {
+			return fmt.Errorf("popd: %w", err)
+		}
+		s.dirStack = s.dirStack[1:]
+	} else {
+		s.dirStack = append(s.dirStack[:n-1:n-1], s.dirStack[n:]...)
+	}
+	return s.dirs(nil, out)
+}
+
+// dirs - встроенная команда dirs [-clpv]: вывод стека каталогов,
+// домашний каталог сокращается до ~ (кроме -l); -p - по одному
+// в строке, -v - с номерами, -c - очистка стека
+func (s *Shell) dirs(args []string, out io.Writer) error {
+	var long, perLine, numbered bool
+	for _, arg := range args {
+		if len(arg) < 2 || arg[0] != '-' {
+			return fmt.Errorf("dirs: %v: invalid argument", arg)
+		}
+		for _, opt := range arg[1:] {
+			switch opt {
+			case 'c':
+				s.dirStack = nil
+				return nil
+			case 'l':
+				long = true
+			case 'p':
+				perLine = true
+			case 'v':
+				perLine, numbered = true, true
+			default:
+				return fmt.Errorf("dirs: -%c: invalid option", opt)
+			}
+		}
+	}
+
+	list := s.dirList()
+	if !long {
+		home, _ := s.Get("HOME")
+		for i, dir := range list {
+			list[i] = tildePath(dir, home)
+		}
+	}
+	if !perLine {
+		_, err := fmt.Fprintln(out, strings.Join(list, " "))
+		return err
+	}
+	for i, dir := range list {
+		var err error
+		if numbered {
+			_, err = fmt.Fprintf(out, "%2d  %v\n", i, dir)
+		} else {
+			_, err = fmt.Fprintln(out, dir)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tildePath - путь с домашним каталогом, сокращённым до ~
+func tildePath(path, home string) string {
+	home = strings.TrimSuffix(home, "/")
+	switch {
+	case home == "":
+		return path
+	case path == home:
+		return "~"
+	case strings.HasPrefix(path, home+"/"):
+		return "~" + path[len(home):]
+	}
+	return path
+}
@@ -0,0 +1,457 @@
+package wbsh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInterrupted - ввод строки прерван Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// histSize - сколько последних команд хранится в истории
+const histSize = 1000
+
+// специальные клавиши, которые терминал передаёт escape-последовательностями
+const (
+	keyUp rune = -1 - iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+// ctrl - код клавиши Ctrl+c
+func ctrl(c byte) rune {
+	return rune(c & 0x1f)
+}
+
+// lineReader - источник строк команд: терминал с редактором или поток
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// scanner - построчное чтение потока, приглашение выводится в out
+type scanner struct {
+	*bufio.Scanner
+	out io.Writer // nil - без приглашения
+}
+
+func (sc *scanner) readLine(prompt string) (string, error) {
+	if sc.out != nil {
+		fmt.Fprint(sc.out, prompt)
+	}
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return sc.Text(), nil
+}
+
+// editor - редактор строки в терминале: перемещение курсора, история
+// со стрелками и поиском Ctrl-R, дополнение по Tab
+type editor struct {
+	in  io.Reader
+	out io.Writer
+	fd  int // терминал для raw-режима, -1 - не переключать режим
+
+	history  []string
+	histFile string // "" - история не сохраняется
+	histPos  int    // позиция при листании истории, len(history) - новая строка
+	saved    string // введённая строка, пока листается история
+
+	// complete - слово перед курсором и варианты его замены
+	complete func(prefix string) (word string, cands []string)
+
+	prompt string
+	line   []rune
+	pos    int
+}
+
+// newEditor - редактор для терминала f с историей из файла histFile
+func newEditor(f *os.File, histFile string) *editor {
+	e := &editor{in: f, out: f, fd: int(f.Fd()), histFile: histFile}
+	e.loadHistory()
+	return e
+}
+
+// loadHistory - чтение истории из файла. addHistory только дописывает
+// в файл, поэтому здесь он обрезается до последних histSize команд
+func (e *editor) loadHistory() {
+	if e.histFile == "" {
+		return
+	}
+	data, err := os.ReadFile(e.histFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > histSize {
+		e.history = e.history[len(e.history)-histSize:]
+		os.WriteFile(e.histFile, []byte(strings.Join(e.history, "\n")+"\n"), 0o600)
+	}
+}
+
+// addHistory - добавление строки в историю и в конец файла истории
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > histSize {
+		e.history = e.history[1:]
+	}
+	if e.histFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.histFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// readByte - чтение одного байта без буферизации: непрочитанный ввод
+// остаётся в терминале для запущенных команд
+func (e *editor) readByte() (byte, error) {
+	var b [1]byte
+	for {
+		n, err := e.in.Read(b[:])
+		if n == 1 {
+			return b[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// readKey - очередная клавиша: символ UTF-8, управляющий код или
+// одна из констант key*
+func (e *editor) readKey() (rune, error) {
+	b, err := e.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b == 0x1b:
+		return e.readEscape()
+	case b < utf8.RuneSelf:
+		return rune(b), nil
+	}
+	buf := []byte{b}
+	for !utf8.FullRune(buf) {
+		if b, err = e.readByte(); err != nil {
+			return 0, err
+		}
+		buf = append(buf, b)
+	}
+	r, _ := utf8.DecodeRune(buf)
+	return r, nil
+}
+
+// readEscape - разбор escape-последовательности после ESC
+func (e *editor) readEscape() (rune, error) {
+	b, err := e.readByte()
+	if err != nil || b != '[' && b != 'O' {
+		return keyUnknown, err
+	}
+	var params []byte
+	for {
+		if b, err = e.readByte(); err != nil {
+			return 0, err
+		}
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+		params = append(params, b)
+	}
+	switch b {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '~':
+		switch string(params) {
+		case "1", "7":
+			return keyHome, nil
+		case "4", "8":
+			return keyEnd, nil
+		case "3":
+			return keyDelete, nil
+		}
+	}
+	return keyUnknown, nil
+}
+
+// refresh - перерисовка строки и установка курсора
+func (e *editor) refresh() {
+	fmt.Fprintf(e.out, "\r%v%v\x1b[K", e.prompt, string(e.line))
+	if n := len(e.line) - e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+// setLine - замена всей строки, курсор в конце
+func (e *editor) setLine(line string) {
+	e.line = []rune(line)
+	e.pos = len(e.line)
+}
+
+// insert - вставка текста в позицию курсора
+func (e *editor) insert(text []rune) {
+	line := make([]rune, 0, len(e.line)+len(text))
+	line = append(append(append(line, e.line[:e.pos]...), text...), e.line[e.pos:]...)
+	e.line = line
+	e.pos += len(text)
+}
+
+// cut - удаление символов с from по to
+func (e *editor) cut(from, to int) {
+	e.line = append(e.line[:from], e.line[to:]...)
+	e.pos = from
+}
+
+// readLine - чтение строки с редактированием. io.EOF - Ctrl-D на
+// пустой строке, errInterrupted - Ctrl-C
+func (e *editor) readLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		restore, err := makeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+	// строки многострочного приглашения, кроме последней, выводятся
+	// один раз: refresh перерисовывает только текущую строку
+	if i := strings.LastIndexByte(prompt, '\n'); i >= 0 {
+		fmt.Fprint(e.out, strings.ReplaceAll(prompt[:i+1], "\n", "\r\n"))
+		prompt = prompt[i+1:]
+	}
+	e.prompt, e.line, e.pos = prompt, nil, 0
+	e.histPos, e.saved = len(e.history), ""
+	e.refresh()
+
+	var pending rune
+	for {
+		key := pending
+		if pending == 0 {
+			var err error
+			if key, err = e.readKey(); err != nil {
+				return "", err
+			}
+		}
+		pending = 0
+
+		switch key {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			line := string(e.line)
+			e.addHistory(line)
+			return line, nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case ctrl('D'):
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if e.pos < len(e.line) {
+				e.cut(e.pos, e.pos+1)
+			}
+		case keyDelete:
+			if e.pos < len(e.line) {
+				e.cut(e.pos, e.pos+1)
+			}
+		case 0x7f, ctrl('H'):
+			if e.pos > 0 {
+				e.cut(e.pos-1, e.pos)
+			}
+		case keyLeft, ctrl('B'):
+			e.pos = max(e.pos-1, 0)
+		case keyRight, ctrl('F'):
+			e.pos = min(e.pos+1, len(e.line))
+		case keyHome, ctrl('A'):
+			e.pos = 0
+		case keyEnd, ctrl('E'):
+			e.pos = len(e.line)
+		case keyUp, ctrl('P'):
+			e.moveHistory(-1)
+		case keyDown, ctrl('N'):
+			e.moveHistory(1)
+		case ctrl('K'):
+			e.line = e.line[:e.pos]
+		case ctrl('U'):
+			e.cut(0, e.pos)
+		case ctrl('W'):
+			from := e.pos
+			for from > 0 && unicode.IsSpace(e.line[from-1]) {
+				from--
+			}
+			for from > 0 && !unicode.IsSpace(e.line[from-1]) {
+				from--
+			}
+			e.cut(from, e.pos)
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case '\t':
+			e.completeWord()
+		case ctrl('R'):
+			pending = e.search()
+		default:
+			if key >= ' ' && key != 0x7f {
+				e.insert([]rune{key})
+			}
+		}
+		e.refresh()
+	}
+}
+
+// moveHistory - переход к предыдущей (-1) или следующей (1) команде
+func (e *editor) moveHistory(dir int) {
+	pos := e.histPos + dir
+	if pos < 0 || pos > len(e.history) {
+		return
+	}
+	if e.histPos == len(e.history) {
+		e.saved = string(e.line)
+	}
+	e.histPos = pos
+	if pos == len(e.history) {
+		e.setLine(e.saved)
+	} else {
+		e.setLine(e.history[pos])
+	}
+}
+
+// search - обратный поиск по истории (Ctrl-R). Найденная строка
+// становится текущей; возвращает клавишу, завершившую поиск, которую
+// нужно обработать обычным образом
+func (e *editor) search() rune {
+	var query []rune
+	orig, origPos := string(e.line), e.pos
+	match := len(e.history)
+	failed := false
+
+	// find - поиск строки с подстрокой query, начиная с from и назад
+	find := func(from int) {
+		for i := min(from, len(e.history)-1); i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				match, failed = i, false
+				e.setLine(e.history[i])
+				e.pos = len([]rune(e.history[i][:strings.Index(e.history[i], string(query))]))
+				return
+			}
+		}
+		failed = true
+	}
+
+	for {
+		status := "reverse-i-search"
+		if failed {
+			status = "failed " + status
+		}
+		fmt.Fprintf(e.out, "\r(%v)'%v': %v\x1b[K", status, string(query), string(e.line))
+
+		key, err := e.readKey()
+		if err != nil {
+			return ctrl('D')
+		}
+		switch {
+		case key == ctrl('R'):
+			if len(query) > 0 {
+				find(match - 1)
+			}
+		case key == 0x7f || key == ctrl('H'):
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history) - 1)
+			}
+		case key == ctrl('G') || key == ctrl('C'):
+			e.setLine(orig)
+			e.pos = origPos
+			return 0
+		case key >= ' ':
+			query = append(query, key)
+			find(match)
+		default:
+			return key
+		}
+	}
+}
+
+// completeWord - дополнение слова перед курсором. Единственный вариант
+// подставляется целиком, несколько - до общего префикса, а если
+// дополнять нечего, выводится их список
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+	word, cands := e.complete(string(e.line[:e.pos]))
+	if len(cands) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+	prefix := cands[0]
+	for _, c := range cands[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			_, n := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-n]
+		}
+	}
+	if len(cands) == 1 && !strings.HasSuffix(prefix, "/") {
+		prefix += " "
+	}
+	if prefix != word {
+		e.cut(e.pos-utf8.RuneCountInString(word), e.pos)
+		e.insert([]rune(prefix))
+		return
+	}
+	e.list(cands)
+}
+
+// list - вывод вариантов дополнения колонками под строкой ввода
+func (e *editor) list(cands []string) {
+	width := 1
+	for _, c := range cands {
+		width = max(width, utf8.RuneCountInString(c)+2)
+	}
+	cols := 80
+	if e.fd >= 0 {
+		cols = termWidth(e.fd)
+	}
+	perLine := max(cols/width, 1)
+
+	fmt.Fprint(e.out, "\r\n")
+	for i, c := range cands {
+		if i%perLine == perLine-1 || i == len(cands)-1 {
+			fmt.Fprintf(e.out, "%v\r\n", c)
+		} else {
+			fmt.Fprintf(e.out, "%-*v", width, c)
+		}
+	}
+}
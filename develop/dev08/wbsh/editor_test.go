@@ -0,0 +1,86 @@
+package wbsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditor(t *testing.T) {
+	testcases := []struct {
+		name    string
+		input   string
+		history []string
+		want    string
+		err     error
+	}{
+		{name: "Plain line", input: "echo hi\r", want: "echo hi"},
+		{name: "Cursor movement", input: "ac\x1b[DbX\x7f\x1b[3~\x1b[H>\x05<\r", want: ">ab<"},
+		{name: "UTF-8 and backspace", input: "привет\x7f\x7fик\r", want: "привик"},
+		{name: "Kill word and line", input: "a b c\x17\x17d\x01\x0b\r", want: ""},
+		{name: "History", input: "\x1b[A\x1b[A\x1b[A\x1b[B\r", history: []string{"one", "two"}, want: "two"},
+		{name: "Reverse search", input: "\x12o\x12\r", history: []string{"foo", "bar", "boo"}, want: "foo"},
+		{name: "Reverse search accepted by a key", input: "\x12ba\x05!\r", history: []string{"bar", "baz"}, want: "baz!"},
+		{name: "Reverse search cancelled", input: "x\x12ba\x07\r", history: []string{"bar"}, want: "x"},
+		{name: "Completion", input: "ec\t\tf\t\r", want: "echo file\\ one "},
+		{name: "Ctrl-C", input: "abc\x03", err: errInterrupted},
+		{name: "Ctrl-D", input: "\x04", err: io.EOF},
+	}
+
+	complete := func(prefix string) (string, []string) {
+		word := prefix[strings.LastIndexByte(prefix, ' ')+1:]
+		var cands []string
+		for _, c := range []string{"echo", "file\\ one", "file\\ two"} {
+			if strings.HasPrefix(c, word) {
+				cands = append(cands, c)
+			}
+		}
+		if word == "f" {
+			cands = cands[:1]
+		}
+		return word, cands
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &editor{in: strings.NewReader(tc.input), out: io.Discard, fd: -1, complete: complete}
+			e.history = tc.history
+			got, err := e.readLine("$ ")
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err: %v, want: %v", err, tc.err)
+			}
+			if got != tc.want {
+				t.Errorf("got: %q, want: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadHistory(t *testing.T) {
+	histFile := filepath.Join(t.TempDir(), ".wbsh_history")
+	var lines []string
+	for i := 0; i < histSize+500; i++ {
+		lines = append(lines, fmt.Sprintf("echo %v", i))
+	}
+	if err := os.WriteFile(histFile, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &editor{histFile: histFile}
+	e.loadHistory()
+	want := lines[len(lines)-histSize:]
+	if len(e.history) != histSize || e.history[0] != want[0] {
+		t.Errorf("history: %v entries from %q", len(e.history), e.history[0])
+	}
+	data, err := os.ReadFile(histFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"); len(got) != histSize || got[0] != want[0] {
+		t.Errorf("file: %v lines from %q, want: %v from %q", len(got), got[0], histSize, want[0])
+	}
+}
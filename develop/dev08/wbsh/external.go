@@ -0,0 +1,112 @@
+package wbsh
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// errNotFound - команды нет ни среди встроенных, ни в $PATH
+var errNotFound = errors.New("command not found")
+
+// startStatus - код возврата при ошибке запуска: 127 - команда
+// не найдена, 126 - найдена, но не может быть запущена
+func startStatus(err error) int {
+	if errors.Is(err, errNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return 127
+	}
+	return 126
+}
+
+// executable - проверка, что по пути лежит исполняемый файл
+func executable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return pathErr.Err
+		}
+		return err
+	}
+	if info.IsDir() {
+		return syscall.EISDIR
+	}
+	if info.Mode()&0o111 == 0 {
+		return fs.ErrPermission
+	}
+	return nil
+}
+
+// LookPath - поиск исполняемого файла по $PATH шелла. Имя со слэшем
+// используется как путь без поиска
+func (s *Shell) LookPath(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		if err := executable(s.path(name)); err != nil {
+			return "", fmt.Errorf("%v: %w", name, err)
+		}
+		return s.path(name), nil
+	}
+	if paths := s.searchPath(name, false); len(paths) > 0 {
+		return paths[0], nil
+	}
+	return "", fmt.Errorf("%v: %w", name, errNotFound)
+}
+
+// searchPath - исполняемые файлы name в каталогах $PATH: первый
+// найденный или, если all, все
+func (s *Shell) searchPath(name string, all bool) []string {
+	var paths []string
+	path, _ := s.Get("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		if file := filepath.Join(s.path(dir), name); executable(file) == nil {
+			paths = append(paths, file)
+			if !all {
+				break
+			}
+		}
+	}
+	return paths
+}
+
+// execShell - встроенная команда exec: замена процесса шелла командой
+// args через syscall.Exec. Потоки команды становятся дескрипторами 0, 1, 2
+func (s *Shell) execShell(args []string, std stdio) int {
+	path, err := s.LookPath(args[0])
+	if err != nil {
+		fmt.Fprintln(std.err, err)
+		return startStatus(err)
+	}
+	for fd, stream := range []any{std.in, std.out, std.err} {
+		if stream == nil {
+			continue
+		}
+		f, ok := stream.(*os.File)
+		if !ok {
+			fmt.Fprintf(std.err, "exec: %v: fd %v is not a file\n", args[0], fd)
+			return 126
+		}
+		if int(f.Fd()) == fd {
+			continue
+		}
+		if err := syscall.Dup3(int(f.Fd()), fd, 0); err != nil {
+			fmt.Fprintf(std.err, "exec: %v\n", err)
+			return 126
+		}
+	}
+	if s.dir != "" {
+		if err := os.Chdir(s.dir); err != nil {
+			fmt.Fprintf(std.err, "exec: %v\n", err)
+			return 126
+		}
+	}
+	err = syscall.Exec(path, args, s.Environ())
+	fmt.Fprintf(std.err, "exec: %v: %v\n", args[0], err)
+	return 126
+}
@@ -0,0 +1,96 @@
+package wbsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	errLocal  = errors.New("local: can only be used in a function")
+	errReturn = errors.New("return: can only 'return' from a function or sourced script")
+)
+
+// call - вызов функции: аргументы становятся позиционными параметрами,
+// переменные, объявленные local, по выходу восстанавливаются
+func (s *Shell) call(f *FuncDecl, args []string, std stdio) int {
+	savedArgs, savedLoops := s.args, s.loops
+	s.args, s.loops = args[1:], 0
+	s.frames = append(s.frames, make(map[string]*variable))
+
+	status := s.run(f.Body, std)
+
+	frame := s.frames[len(s.frames)-1]
+	s.frames = s.frames[:len(s.frames)-1]
+	for name, v := range frame {
+		if v == nil {
+			delete(s.vars, name)
+		} else {
+			s.vars[name] = v
+		}
+	}
+	s.args, s.loops = savedArgs, savedLoops
+	s.returning = false
+	return status
+}
+
+// local - встроенная команда local NAME[=value]...: переменная
+// видна только до выхода из функции
+func (s *Shell) local(args []string) error {
+	if len(s.frames) == 0 {
+		return errLocal
+	}
+	frame := s.frames[len(s.frames)-1]
+	for _, arg := range args {
+		name, value, _ := strings.Cut(arg, "=")
+		if !isName(name) {
+			return fmt.Errorf("local: '%v': not a valid identifier", arg)
+		}
+		if _, ok := frame[name]; !ok {
+			frame[name] = s.vars[name]
+		}
+		s.vars[name] = &variable{value: value}
+	}
+	return nil
+}
+
+// returnCmd - встроенная команда return [N]
+func (s *Shell) returnCmd(args []string, errOut io.Writer) error {
+	if len(s.frames) == 0 && s.sourcing == 0 {
+		return errReturn
+	}
+	status := s.Status
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(errOut, "return: %v: numeric argument required\n", args[0])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	s.returning = true
+	return exitCode(status)
+}
+
+// loopCtl - встроенные команды break [N] и continue [N]
+func (s *Shell) loopCtl(name string, args []string) error {
+	if s.loops == 0 {
+		return fmt.Errorf("%v: only meaningful in a 'for', 'while', or 'until' loop", name)
+	}
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			return fmt.Errorf("%v: %v: loop count out of range", name, args[0])
+		}
+	}
+	n = min(n, s.loops)
+	if name == "break" {
+		s.breaking = n
+	} else {
+		s.continuing = n
+	}
+	return nil
+}
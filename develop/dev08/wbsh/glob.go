@@ -0,0 +1,160 @@
+package wbsh
+
+import (
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+)
+
+// expandTilde - раскрытие ~ и ~user в начале слова в домашний каталог,
+// ~+ и ~- - в $PWD и $OLDPWD. Префикс до первого / не должен быть
+// в кавычках; если каталог не найден, слово остаётся как есть
+func (s *Shell) expandTilde(w Word) Word {
+	if len(w) == 0 || w[0].Quoted || w[0].isSubst() || !strings.HasPrefix(w[0].Text, "~") {
+		return w
+	}
+	prefix, rest, slash := strings.Cut(w[0].Text[1:], "/")
+	if !slash && len(w) > 1 {
+		return w
+	}
+
+	var dir string
+	switch prefix {
+	case "":
+		dir, _ = s.Get("HOME")
+		if dir == "" {
+			if u, err := user.Current(); err == nil {
+				dir = u.HomeDir
+			}
+		}
+	case "+":
+		dir, _ = s.Get("PWD")
+	case "-":
+		dir, _ = s.Get("OLDPWD")
+	default:
+		if u, err := user.Lookup(prefix); err == nil {
+			dir = u.HomeDir
+		}
+	}
+	if dir == "" {
+		return w
+	}
+
+	expanded := Word{{Text: dir, Quoted: true}}
+	if slash {
+		expanded = append(expanded, WordPart{Text: "/" + rest})
+	}
+	return append(expanded, w[1:]...)
+}
+
+// glob - пути, подходящие под шаблон, по алфавиту. Шаблон делится на
+// компоненты по /, ** в отдельной компоненте - любое число каталогов.
+// Скрытые файлы подходят, только если компонента начинается с точки
+func (s *Shell) glob(pattern string) []string {
+	paths := []string{""}
+	if strings.HasPrefix(pattern, "/") {
+		paths = []string{"/"}
+	}
+	parts := strings.Split(strings.Trim(pattern, "/"), "/")
+	for i, part := range parts {
+		last := i == len(parts)-1
+		var next []string
+		for _, base := range paths {
+			switch {
+			case part == "**" && last:
+				next = append(next, s.walkDirs(base, true)...)
+			case part == "**":
+				next = append(next, base)
+				next = append(next, s.walkDirs(base, false)...)
+			case !hasMeta(part):
+				next = append(next, base+unescape(part))
+			default:
+				next = append(next, s.matchDir(base, part, last)...)
+			}
+		}
+		if !last {
+			for j, path := range next {
+				if path != "" && !strings.HasSuffix(path, "/") {
+					next[j] = path + "/"
+				}
+			}
+		}
+		paths = next
+	}
+
+	// шаблон со слэшем в конце подходит только к каталогам
+	dirsOnly := strings.HasSuffix(pattern, "/")
+	matches := paths[:0]
+	for _, path := range paths {
+		switch {
+		case path == "":
+		case dirsOnly && isDir(s.path(path)):
+			matches = append(matches, path+"/")
+		case dirsOnly:
+		case exists(s.path(path)):
+			matches = append(matches, path)
+		}
+	}
+	slices.Sort(matches)
+	return matches
+}
+
+// readDir - имена файлов каталога dir ("" - текущий каталог)
+func readDir(dir string) []os.DirEntry {
+	if dir == "" {
+		dir = "."
+	}
+	entries, _ := os.ReadDir(dir)
+	return entries
+}
+
+// isDir - путь ведёт в каталог, в том числе по символической ссылке
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// matchDir - файлы каталога base, подходящие под компоненту шаблона.
+// Если компонента не последняя, подходят только каталоги
+func (s *Shell) matchDir(base, part string, last bool) []string {
+	var paths []string
+	for _, entry := range readDir(s.path(base)) {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(part, ".") {
+			continue
+		}
+		if !matchPattern(part, name) || !last && !isDir(s.path(base+name)) {
+			continue
+		}
+		paths = append(paths, base+name)
+	}
+	return paths
+}
+
+// walkDirs - раскрытие **: все вложенные в base каталоги, а если
+// files - то и файлы. Скрытые файлы пропускаются, по ссылкам переход
+// не выполняется
+func (s *Shell) walkDirs(base string, files bool) []string {
+	var paths []string
+	for _, entry := range readDir(s.path(base)) {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := base + name
+		if entry.IsDir() {
+			paths = append(paths, path)
+			paths = append(paths, s.walkDirs(path+"/", files)...)
+		} else if files {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// exists - файл существует (ссылка может быть и битой)
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,698 @@
+package wbsh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	errNoJob   = errors.New("no current job")
+	errJobSpec = errors.New("no such job")
+)
+
+// exitCode - результат встроенной команды, у которой есть только
+// код возврата без сообщения (fg, wait)
+type exitCode int
+
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// jobState - состояние задания
+type jobState int
+
+const (
+	jobRunning jobState = iota
+	jobStopped
+	jobDone
+)
+
+func (st jobState) String() string {
+	return [...]string{"Running", "Stopped", "Done"}[st]
+}
+
+// process - внешний процесс задания
+type process struct {
+	cmd     *exec.Cmd
+	stopped int  // сигнал, которым процесс остановлен; 0 - работает
+	exited  bool // процесс завершился, его больше нельзя считать членом группы
+	done    bool
+	status  int
+}
+
+// Job - задание: команда из одной строки со всеми её процессами.
+// Фоновые задания получают собственную группу процессов
+type Job struct {
+	ID   int
+	Text string
+
+	mu          sync.Mutex
+	cond        *sync.Cond
+	background  bool
+	group       bool // процессы в отдельной группе pgid
+	tty         int  // терминал, который получает группа на переднем плане; -1 - нет
+	pgid        int
+	procs       []*process
+	starting    int  // сколько процессов сейчас запускается
+	running     bool // команда ещё выполняется шеллом
+	inBuiltin   bool // задание начало со встроенной команды, процесса может не быть
+	interrupted bool // процесс задания убит SIGINT
+	status      int
+
+	termios *syscall.Termios // режим терминала остановленного задания
+
+	// ctx отменяется сигналом, завершающим задание: так его получают
+	// встроенные команды, у которых нет своего процесса
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newJob - новое задание для команды text
+func newJob(text string, background bool) *Job {
+	j := &Job{Text: text, background: background, group: background, tty: -1, running: true}
+	j.cond = sync.NewCond(&j.mu)
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+	return j
+}
+
+// sysProcAttr - атрибуты запуска процесса задания, вызывается под j.mu.
+// Процессы собираются в группу первого из них; если все процессы
+// группы завершились, следующий создаёт новую и на переднем плане
+// сам забирает терминал
+func (j *Job) sysProcAttr() *syscall.SysProcAttr {
+	if !j.group {
+		return nil
+	}
+	alive := false
+	for _, p := range j.procs {
+		alive = alive || !p.exited
+	}
+	if !alive {
+		j.pgid = 0
+	}
+	attr := &syscall.SysProcAttr{Setpgid: true, Pgid: j.pgid}
+	if j.pgid == 0 && j.tty >= 0 && !j.background {
+		attr.Foreground, attr.Ctty = true, j.tty
+	}
+	return attr
+}
+
+// start - запуск процесса задания и слежение за ним. Пока процесс
+// запускается, завершившиеся процессы задания не забираются: иначе
+// группа pgid может исчезнуть до того, как он в неё войдёт
+func (j *Job) start(cmd *exec.Cmd) (*process, error) {
+	j.mu.Lock()
+	cmd.SysProcAttr = j.sysProcAttr()
+	j.starting++
+	j.mu.Unlock()
+
+	err := cmd.Start()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.starting--
+	j.cond.Broadcast()
+	if err != nil {
+		return nil, err
+	}
+	p := &process{cmd: cmd}
+	if j.pgid == 0 {
+		j.pgid = cmd.Process.Pid
+	}
+	j.procs = append(j.procs, p)
+	go j.watch(p)
+	return p, nil
+}
+
+// Pgid - группа процессов задания (pid первого процесса)
+func (j *Job) Pgid() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.pgid
+}
+
+// Interrupted - процесс задания был убит SIGINT (Ctrl-C)
+func (j *Job) Interrupted() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.interrupted
+}
+
+// finish - шелл закончил выполнять команду задания
+func (j *Job) finish(status int) {
+	j.mu.Lock()
+	j.running = false
+	j.status = status
+	j.cond.Broadcast()
+	j.mu.Unlock()
+}
+
+// state - состояние задания, вызывается под j.mu
+func (j *Job) state() jobState {
+	done := !j.running
+	for _, p := range j.procs {
+		if p.stopped != 0 && !p.done {
+			return jobStopped
+		}
+		done = done && p.done
+	}
+	if done {
+		return jobDone
+	}
+	return jobRunning
+}
+
+// alive - у задания есть незавершившиеся процессы
+func (j *Job) alive() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, p := range j.procs {
+		if !p.done {
+			return true
+		}
+	}
+	return false
+}
+
+// State - текущее состояние задания
+func (j *Job) State() jobState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state()
+}
+
+// waitProc - ожидание процесса. Процесс переднего плана может быть
+// остановлен (Ctrl-Z), тогда возвращается 128+номер сигнала
+func (j *Job) waitProc(p *process) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for {
+		if p.done {
+			return p.status
+		}
+		if p.stopped != 0 && !j.background {
+			return 128 + p.stopped
+		}
+		j.cond.Wait()
+	}
+}
+
+// wait - ожидание завершения задания, а если untilStop - то и его остановки
+func (j *Job) wait(untilStop bool) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for {
+		switch j.state() {
+		case jobDone:
+			return j.status
+		case jobStopped:
+			if untilStop {
+				for _, p := range j.procs {
+					if p.stopped != 0 {
+						return 128 + p.stopped
+					}
+				}
+			}
+		}
+		j.cond.Wait()
+	}
+}
+
+// waitStarted - ожидание первого процесса задания, запуска встроенной
+// команды или конца команды, если в ней нет ни того, ни другого
+func (j *Job) waitStarted() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for j.pgid == 0 && j.running && !j.inBuiltin {
+		j.cond.Wait()
+	}
+}
+
+// builtinStarted - в задании запущена встроенная команда
+func (j *Job) builtinStarted() {
+	j.mu.Lock()
+	j.inBuiltin = true
+	j.cond.Broadcast()
+	j.mu.Unlock()
+}
+
+// signal - отправка сигнала всем процессам задания. Сигналы
+// завершения отменяют и встроенные команды задания
+func (j *Job) signal(sig syscall.Signal) error {
+	switch sig {
+	case syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGKILL, syscall.SIGHUP:
+		j.cancel()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.group && j.pgid != 0 {
+		return syscall.Kill(-j.pgid, sig)
+	}
+	var errs []error
+	for _, p := range j.procs {
+		if !p.done {
+			errs = append(errs, syscall.Kill(p.cmd.Process.Pid, sig))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resume - продолжение остановленного задания в фоне или на переднем плане
+func (j *Job) resume(background bool) error {
+	j.mu.Lock()
+	j.background = background
+	for _, p := range j.procs {
+		p.stopped = 0
+	}
+	j.mu.Unlock()
+	return j.signal(syscall.SIGCONT)
+}
+
+// константы waitid(2), которых нет в пакете syscall
+const (
+	pPID         = 1
+	cldStopped   = 5
+	cldContinued = 6
+)
+
+// siginfo - начало siginfo_t, заполняемой waitid(2) для SIGCHLD
+type siginfo struct {
+	Signo  int32
+	Errno  int32
+	Code   int32
+	_      int32
+	Pid    int32
+	UID    uint32
+	Status int32
+	_      [100]byte
+}
+
+// waitid - обёртка над системным вызовом waitid(2) для одного процесса
+func waitid(pid int, options int) (siginfo, error) {
+	var info siginfo
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid),
+			uintptr(unsafe.Pointer(&info)), uintptr(options), 0, 0)
+		if errno != syscall.EINTR {
+			if errno != 0 {
+				return info, errno
+			}
+			return info, nil
+		}
+	}
+}
+
+// watch - слежение за остановкой и продолжением процесса. Завершение
+// только подсматривается (WNOWAIT), а забирает процесс cmd.Wait,
+// чтобы дождаться копирования его вывода
+func (j *Job) watch(p *process) {
+	pid := p.cmd.Process.Pid
+	for {
+		info, err := waitid(pid, syscall.WEXITED|syscall.WSTOPPED|syscall.WCONTINUED|syscall.WNOWAIT)
+		if err != nil || info.Code != cldStopped && info.Code != cldContinued {
+			break
+		}
+		_, _ = waitid(pid, syscall.WSTOPPED|syscall.WCONTINUED|syscall.WNOHANG)
+
+		j.mu.Lock()
+		p.stopped = 0
+		if info.Code == cldStopped {
+			p.stopped = int(info.Status)
+		}
+		j.cond.Broadcast()
+		j.mu.Unlock()
+	}
+
+	j.mu.Lock()
+	for j.starting > 0 {
+		j.cond.Wait()
+	}
+	p.exited = true
+	j.mu.Unlock()
+
+	err := p.cmd.Wait()
+	j.mu.Lock()
+	p.done, p.stopped, p.status = true, 0, exitStatus(err)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		ws, ok := exitErr.Sys().(syscall.WaitStatus)
+		j.interrupted = j.interrupted || ok && ws.Signaled() && ws.Signal() == syscall.SIGINT
+	}
+	if !j.running {
+		j.status = p.status
+	}
+	j.cond.Broadcast()
+	j.mu.Unlock()
+}
+
+// jobTable - таблица заданий шелла
+type jobTable struct {
+	mu   sync.Mutex
+	list []*Job
+	fg   *Job   // задание переднего плана
+	live []*Job // задания с процессами, включая подстановки команд
+}
+
+// track - задание запустило процесс. Завершившиеся задания из списка
+// убираются
+func (t *jobTable) track(j *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	live := t.live[:0]
+	for _, job := range t.live {
+		if job != j && job.alive() {
+			live = append(live, job)
+		}
+	}
+	t.live = append(live, j)
+}
+
+// alive - задания, у которых остались работающие процессы
+func (t *jobTable) alive() []*Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var jobs []*Job
+	for _, j := range t.live {
+		if j.alive() {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs
+}
+
+// setForeground - задание переднего плана, nil - его нет
+func (t *jobTable) setForeground(j *Job) {
+	t.mu.Lock()
+	t.fg = j
+	t.mu.Unlock()
+}
+
+// foreground - текущее задание переднего плана
+func (t *jobTable) foreground() *Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.fg
+}
+
+// add - добавление задания в таблицу с очередным номером
+func (t *jobTable) add(j *Job) *Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.ID = 1
+	if n := len(t.list); n > 0 {
+		j.ID = t.list[n-1].ID + 1
+	}
+	t.list = append(t.list, j)
+	return j
+}
+
+// remove - удаление задания из таблицы
+func (t *jobTable) remove(j *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, job := range t.list {
+		if job == j {
+			t.list = append(t.list[:i], t.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// moveToEnd - задание становится текущим (%+)
+func (t *jobTable) moveToEnd(j *Job) {
+	t.remove(j)
+	t.mu.Lock()
+	t.list = append(t.list, j)
+	t.mu.Unlock()
+}
+
+// find - поиск задания по спецификации: %n, %%, %+, %-, %строка.
+// Пустая спецификация - текущее задание
+func (t *jobTable) find(spec string) (*Job, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := len(t.list)
+	switch spec {
+	case "", "%", "%%", "%+":
+		if n == 0 {
+			return nil, errNoJob
+		}
+		return t.list[n-1], nil
+	case "%-":
+		if n < 2 {
+			return nil, fmt.Errorf("%v: %w", spec, errJobSpec)
+		}
+		return t.list[n-2], nil
+	}
+	if !strings.HasPrefix(spec, "%") {
+		return nil, fmt.Errorf("%v: %w", spec, errJobSpec)
+	}
+	if id, err := strconv.Atoi(spec[1:]); err == nil {
+		for _, j := range t.list {
+			if j.ID == id {
+				return j, nil
+			}
+		}
+	} else {
+		for i := n - 1; i >= 0; i-- {
+			if strings.HasPrefix(t.list[i].Text, spec[1:]) {
+				return t.list[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%v: %w", spec, errJobSpec)
+}
+
+// findPid - задание, в которое входит процесс pid
+func (t *jobTable) findPid(pid int) *Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, j := range t.list {
+		j.mu.Lock()
+		for _, p := range j.procs {
+			if p.cmd.Process.Pid == pid {
+				j.mu.Unlock()
+				return j
+			}
+		}
+		j.mu.Unlock()
+	}
+	return nil
+}
+
+// print - вывод строк таблицы заданий. only отбирает задания, которые
+// нужно вывести; завершённые из них удаляются из таблицы
+func (t *jobTable) print(w io.Writer, only func(jobState) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := t.list[:0]
+	for i, j := range t.list {
+		mark := " "
+		switch i {
+		case len(t.list) - 1:
+			mark = "+"
+		case len(t.list) - 2:
+			mark = "-"
+		}
+
+		j.mu.Lock()
+		state, status := j.state(), j.status
+		j.mu.Unlock()
+		if !only(state) {
+			list = append(list, j)
+			continue
+		}
+		text := state.String()
+		if state == jobDone && status != 0 {
+			text = fmt.Sprintf("Exit %v", status)
+		}
+		fmt.Fprintf(w, "[%v]%v  %-24v%v\n", j.ID, mark, text, j.Text)
+		if state != jobDone {
+			list = append(list, j)
+		}
+	}
+	t.list = list
+}
+
+// notify - сообщения о завершившихся фоновых заданиях перед приглашением
+func (t *jobTable) notify(w io.Writer) {
+	t.print(w, func(st jobState) bool { return st == jobDone })
+}
+
+// background - запуск команды в фоне как нового задания
+func (s *Shell) background(stmt *Stmt, std stdio) {
+	job := s.jobs.add(newJob(stmt.Text, true))
+	std.job = job
+	sub := s.subshell()
+	// Ctrl-C не прерывает фоновые задания
+	sub.intr = &atomic.Bool{}
+	go func() {
+		job.finish(sub.run(stmt.Node, std))
+	}()
+	job.waitStarted()
+	s.lastJob = job
+	if pgid := job.Pgid(); pgid != 0 {
+		s.lastBg = strconv.Itoa(pgid)
+		fmt.Fprintf(std.err, "[%v] %v\n", job.ID, pgid)
+		return
+	}
+	// задание только из встроенных команд: процесса нет, $! - само задание
+	s.lastBg = "%" + strconv.Itoa(job.ID)
+	fmt.Fprintf(std.err, "[%v]\n", job.ID)
+}
+
+// foreground - выполнение команды на переднем плане. Если её процессы
+// остановлены (Ctrl-Z), задание попадает в таблицу
+func (s *Shell) foreground(stmt *Stmt, std stdio) int {
+	if std.job != nil {
+		return s.run(stmt.Node, std)
+	}
+	job := newJob(stmt.Text, false)
+	switch {
+	case s.jobControl:
+		job.group, job.tty = true, s.tty
+	case s.groupJobs:
+		job.group = true
+	}
+	std.job = job
+	s.jobs.setForeground(job)
+	status := s.run(stmt.Node, std)
+	job.finish(status)
+	s.jobs.setForeground(nil)
+	s.takeTerminal(job)
+	if job.State() == jobStopped {
+		s.jobs.add(job)
+		s.stopped(job, std.err)
+	}
+	return status
+}
+
+// hangup - завершение всех процессов шелла: SIGHUP (остановленным ещё
+// SIGCONT), а тем, кто пережил его дольше grace, - SIGKILL
+func (s *Shell) hangup(grace time.Duration) {
+	jobs := s.jobs.alive()
+	for _, j := range jobs {
+		j.signal(syscall.SIGHUP)
+		j.signal(syscall.SIGCONT)
+	}
+	deadline := time.Now().Add(grace)
+	for _, j := range jobs {
+		for j.alive() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if j.alive() {
+			j.signal(syscall.SIGKILL)
+		}
+	}
+}
+
+// stopped - сообщение об остановленном задании
+func (s *Shell) stopped(job *Job, w io.Writer) {
+	fmt.Fprintf(w, "\n[%v]+  %-24v%v\n", job.ID, jobStopped, job.Text)
+}
+
+// jobsCmd - встроенная команда jobs
+func (s *Shell) jobsCmd(out io.Writer) error {
+	s.jobs.print(out, func(jobState) bool { return true })
+	return nil
+}
+
+// fg - продолжение задания на переднем плане
+func (s *Shell) fg(args []string, std stdio) error {
+	if len(args) > 1 {
+		return errors.New("fg: too many arguments")
+	}
+	job, err := s.jobs.find(strings.Join(args, ""))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(std.out, job.Text)
+	s.giveTerminal(job)
+	s.jobs.setForeground(job)
+	defer s.jobs.setForeground(nil)
+	if err := job.resume(false); err != nil {
+		s.takeTerminal(job)
+		return err
+	}
+	status := job.wait(true)
+	s.takeTerminal(job)
+	if job.State() == jobStopped {
+		s.jobs.moveToEnd(job)
+		s.stopped(job, std.err)
+	} else {
+		s.jobs.remove(job)
+	}
+	return exitCode(status)
+}
+
+// bg - продолжение остановленных заданий в фоне
+func (s *Shell) bg(args []string, std stdio) error {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	for _, spec := range args {
+		job, err := s.jobs.find(spec)
+		if err != nil {
+			return err
+		}
+		if err := job.resume(true); err != nil {
+			return err
+		}
+		fmt.Fprintf(std.out, "[%v]+ %v &\n", job.ID, job.Text)
+	}
+	return nil
+}
+
+// wait - ожидание фоновых заданий: всех (код возврата 0) или указанных
+// по %n и pid (код последнего из них)
+func (s *Shell) wait(args []string) error {
+	var jobs []*Job
+	if len(args) == 0 {
+		s.jobs.mu.Lock()
+		jobs = append(jobs, s.jobs.list...)
+		s.jobs.mu.Unlock()
+	}
+	for _, arg := range args {
+		if arg == s.lastBg && s.lastJob != nil {
+			jobs = append(jobs, s.lastJob)
+			continue
+		}
+		if pid, err := strconv.Atoi(arg); err == nil {
+			job := s.jobs.findPid(pid)
+			if job == nil {
+				return fmt.Errorf("pid %v is not a child of this shell", pid)
+			}
+			jobs = append(jobs, job)
+			continue
+		}
+		job, err := s.jobs.find(arg)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	status := 0
+	for _, job := range jobs {
+		if len(args) == 0 && job.State() == jobStopped {
+			continue
+		}
+		if st := job.wait(false); len(args) > 0 {
+			status = st
+		}
+		if job.State() == jobDone {
+			s.jobs.remove(job)
+		}
+	}
+	return exitCode(status)
+}
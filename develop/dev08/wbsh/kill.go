@@ -0,0 +1,138 @@
+package wbsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalNames - имена сигналов Linux без префикса SIG по номерам
+var signalNames = [...]string{
+	1: "HUP", "INT", "QUIT", "ILL", "TRAP", "ABRT", "BUS", "FPE", "KILL", "USR1",
+	"SEGV", "USR2", "PIPE", "ALRM", "TERM", "STKFLT", "CHLD", "CONT", "STOP", "TSTP",
+	"TTIN", "TTOU", "URG", "XCPU", "XFSZ", "VTALRM", "PROF", "WINCH", "IO", "PWR", "SYS",
+}
+
+// parseSignal - сигнал по номеру или имени: 9, KILL, SIGKILL, kill
+func parseSignal(name string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n >= 0 && n < len(signalNames) {
+			return syscall.Signal(n), nil
+		}
+	} else {
+		upper := strings.TrimPrefix(strings.ToUpper(name), "SIG")
+		for n, s := range signalNames {
+			if s != "" && s == upper {
+				return syscall.Signal(n), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%v: invalid signal specification", name)
+}
+
+// listSignals - kill -l: без аргументов таблица всех сигналов, иначе
+// имя по номеру (или коду возврата 128+N) и номер по имени
+func listSignals(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		for n := 1; n < len(signalNames); n++ {
+			sep := "\t"
+			if n%5 == 0 || n == len(signalNames)-1 {
+				sep = "\n"
+			}
+			fmt.Fprintf(out, "%2d) SIG%v%v", n, signalNames[n], sep)
+		}
+		return nil
+	}
+	for _, arg := range args {
+		if n, err := strconv.Atoi(arg); err == nil && n > 128 {
+			arg = strconv.Itoa(n - 128)
+		}
+		sig, err := parseSignal(arg)
+		if err != nil || sig == 0 {
+			return fmt.Errorf("kill: %v: invalid signal specification", arg)
+		}
+		if _, err := strconv.Atoi(arg); err == nil {
+			fmt.Fprintln(out, signalNames[sig])
+		} else {
+			fmt.Fprintln(out, int(sig))
+		}
+	}
+	return nil
+}
+
+// kill - встроенная команда kill [-s SIG | -SIG | -n N] цель... и kill -l.
+// Цель - pid, -pgid для группы процессов или %задание. Ошибки по каждой
+// цели выводятся в std.err, код возврата тогда 1
+func (s *Shell) kill(args []string, std stdio) error {
+	sig := syscall.SIGTERM
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "--" {
+		opt := args[0]
+		args = args[1:]
+		var name string
+		switch opt {
+		case "-l", "-L":
+			return listSignals(args, std.out)
+		case "-s", "-n":
+			if len(args) == 0 {
+				return fmt.Errorf("kill: %v: option requires an argument", opt)
+			}
+			name, args = args[0], args[1:]
+		default:
+			name = opt[1:]
+		}
+		var err error
+		if sig, err = parseSignal(name); err != nil {
+			return fmt.Errorf("kill: %w", err)
+		}
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return errKill
+	}
+
+	failed := false
+	for _, target := range args {
+		if err := s.killTarget(target, sig); err != nil {
+			fmt.Fprintf(std.err, "kill: %v\n", err)
+			failed = true
+		}
+	}
+	if failed {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// killTarget - отправка сигнала одной цели: pid, -pgid или %задание
+func (s *Shell) killTarget(target string, sig syscall.Signal) error {
+	if strings.HasPrefix(target, "%") {
+		job, err := s.jobs.find(target)
+		if err != nil {
+			return err
+		}
+		if err := job.signal(sig); err != nil {
+			return fmt.Errorf("%v: %w", target, err)
+		}
+		// остановленное задание не обработает сигнал, пока не продолжится
+		if job.State() == jobStopped && sig != syscall.SIGCONT && sig != syscall.SIGKILL && sig != 0 {
+			return job.signal(syscall.SIGCONT)
+		}
+		return nil
+	}
+	pid, err := strconv.Atoi(target)
+	if err != nil {
+		return fmt.Errorf("%v: arguments must be process or job IDs", target)
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("(%v) - No such process", pid)
+		}
+		return fmt.Errorf("(%v) - %w", pid, err)
+	}
+	return nil
+}
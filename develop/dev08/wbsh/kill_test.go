@@ -0,0 +1,62 @@
+package wbsh
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	testcases := []struct {
+		name string
+		want syscall.Signal
+		err  bool
+	}{
+		{name: "9", want: syscall.SIGKILL},
+		{name: "INT", want: syscall.SIGINT},
+		{name: "SIGTERM", want: syscall.SIGTERM},
+		{name: "sigusr1", want: syscall.SIGUSR1},
+		{name: "0", want: 0},
+		{name: "FOO", err: true},
+		{name: "99", err: true},
+	}
+
+	for _, tc := range testcases {
+		got, err := parseSignal(tc.name)
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("parseSignal(%q) = %v, %v; want: %v", tc.name, got, err, tc.want)
+		}
+	}
+
+	var out strings.Builder
+	if err := listSignals([]string{"130", "KILL"}, &out); err != nil || out.String() != "INT\n9\n" {
+		t.Errorf("kill -l: %q, %v", out.String(), err)
+	}
+}
+
+func TestKill(t *testing.T) {
+	testcases := []struct {
+		name  string
+		input string
+		out   string
+	}{
+		{name: "Job spec", input: "sleep 5 &\nkill %1; wait %1; echo $?", out: "[1] $PID\n143\n"},
+		{name: "Process group", input: "sleep 5 | sleep 5 &\nsleep 0.2\nkill -KILL -$!; wait $!; echo $?", out: "[1] $PID\n137\n"},
+		{
+			name:  "Failed targets",
+			input: "sleep 5 &\nkill -INT %2 999999 %1 nope; echo $?; wait %1; echo $?",
+			out:   "[1] $PID\nkill: %2: no such job\nkill: (999999) - No such process\nkill: nope: arguments must be process or job IDs\n1\n130\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			sh := NewShell(&out, strings.NewReader(""))
+			sh.Script(strings.NewReader(tc.input))
+			if got := bgPid.ReplaceAllString(out.String(), "$1 $$PID"); got != tc.out {
+				t.Errorf("out: %q, want: %q", got, tc.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,565 @@
+package wbsh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errIncomplete - ввод оборвался посреди команды (незакрытая кавычка,
+// '|' или '&&' в конце строки): нужно дочитать следующую строку
+var errIncomplete = errors.New("unexpected end of input")
+
+// errBadSubst - неверная подстановка ${...}
+var errBadSubst = errors.New("bad substitution")
+
+// tokKind - вид лексемы
+type tokKind int
+
+const (
+	tokEOF       tokKind = iota
+	tokWord              // слово
+	tokNewline           // \n
+	tokSemi              // ;
+	tokAmp               // &
+	tokPipe              // |
+	tokAnd               // &&
+	tokOr                // ||
+	tokLess              // <
+	tokGreat             // >
+	tokDGreat            // >>
+	tokGreatAnd          // >&
+	tokAndGreat          // &>
+	tokDSemi             // ;;
+	tokLParen            // (
+	tokRParen            // )
+	tokDLess             // <<
+	tokDLessDash         // <<-
+	tokTLess             // <<<
+)
+
+var tokNames = map[tokKind]string{
+	tokEOF:       "newline",
+	tokNewline:   "newline",
+	tokSemi:      ";",
+	tokAmp:       "&",
+	tokPipe:      "|",
+	tokAnd:       "&&",
+	tokOr:        "||",
+	tokLess:      "<",
+	tokGreat:     ">",
+	tokDGreat:    ">>",
+	tokGreatAnd:  ">&",
+	tokAndGreat:  "&>",
+	tokDSemi:     ";;",
+	tokLParen:    "(",
+	tokRParen:    ")",
+	tokDLess:     "<<",
+	tokDLessDash: "<<-",
+	tokTLess:     "<<<",
+}
+
+// WordPart - часть слова: текст, подстановка параметра или команды.
+// Quoted - часть была в кавычках или экранирована: она не делится
+// на поля и не подлежит дальнейшим подстановкам
+type WordPart struct {
+	Text   string
+	Param  *ParamExp
+	Cmd    *CmdSubst
+	Quoted bool
+}
+
+// isSubst - часть - подстановка, а не текст
+func (p WordPart) isSubst() bool {
+	return p.Param != nil || p.Cmd != nil
+}
+
+// CmdSubst - подстановка вывода команды $(...) или `...`
+type CmdSubst struct {
+	List *List
+	Text string // исходный текст команды
+}
+
+// ParamExp - подстановка параметра $NAME или ${NAME<Op>Word}
+type ParamExp struct {
+	Name string
+	Op   string // "", "-", ":-", "=", ":=", "+", ":+"
+	Word Word
+}
+
+// Word - слово командной строки из частей с разным экранированием
+type Word []WordPart
+
+// String - текст слова без кавычек, подстановки в исходном виде
+func (w Word) String() string {
+	var b strings.Builder
+	for _, p := range w {
+		switch {
+		case p.Cmd != nil:
+			b.WriteString("$(" + p.Cmd.Text + ")")
+		case p.Param == nil:
+			b.WriteString(p.Text)
+		case p.Param.Op == "":
+			b.WriteString("${" + p.Param.Name + "}")
+		default:
+			b.WriteString("${" + p.Param.Name + p.Param.Op + p.Param.Word.String() + "}")
+		}
+	}
+	return b.String()
+}
+
+// addText - добавление текста к слову, соседние части с одинаковым
+// экранированием склеиваются
+func (w *Word) addText(text string, quoted bool) {
+	if n := len(*w); n > 0 && !(*w)[n-1].isSubst() && (*w)[n-1].Quoted == quoted {
+		(*w)[n-1].Text += text
+		return
+	}
+	*w = append(*w, WordPart{Text: text, Quoted: quoted})
+}
+
+// token - лексема. Fd - номер дескриптора перед перенаправлением (2>),
+// -1 если не указан; pos - смещение начала лексемы в строке
+type token struct {
+	kind tokKind
+	word Word
+	fd   int
+	pos  int
+}
+
+// String - текст лексемы для сообщений об ошибках
+func (t token) String() string {
+	if t.kind == tokWord {
+		return t.word.String()
+	}
+	return tokNames[t.kind]
+}
+
+// lexer - разбиение строки на лексемы. Подстановка алиаса заменяет
+// слово в src его текстом; expanding - конец подставленного текста
+// по имени алиаса, внутри него тот же алиас не раскрывается.
+// heredocs - here-документы текущей строки, их тела читаются после
+// её перевода строки
+type lexer struct {
+	src       string
+	pos       int
+	aliases   map[string]string
+	expanding map[string]int
+	heredocs  []*Redirect
+	heredoc   bool // разбор тела here-документа: " не экранируется
+}
+
+// expandAlias - замена слова src[start:end] текстом алиаса name,
+// чтение продолжается с начала подставленного текста
+func (l *lexer) expandAlias(name string, start, end int) {
+	value := l.aliases[name]
+	delta := len(value) - (end - start)
+	for n, e := range l.expanding {
+		if e > start {
+			l.expanding[n] = e + delta
+		}
+	}
+	l.src = l.src[:start] + value + l.src[end:]
+	l.expanding[name] = start + len(value)
+	l.pos = start
+}
+
+// inAlias - позиция pos внутри текста, подставленного вместо алиаса name
+func (l *lexer) inAlias(name string, pos int) bool {
+	end, ok := l.expanding[name]
+	return ok && pos < end
+}
+
+// peekByte - символ на смещении off от текущего, 0 за концом строки
+func (l *lexer) peekByte(off int) byte {
+	if l.pos+off < len(l.src) {
+		return l.src[l.pos+off]
+	}
+	return 0
+}
+
+// isMeta - символ, завершающий слово
+func isMeta(c byte) bool {
+	return strings.IndexByte(" \t\n|&;<>()", c) >= 0
+}
+
+// next - очередная лексема
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' {
+			l.pos++
+		} else if c == '\\' && l.peekByte(1) == '\n' {
+			l.pos += 2
+			if l.pos == len(l.src) {
+				return token{}, errIncomplete
+			}
+		} else {
+			break
+		}
+	}
+	// комментарий до конца строки
+	if l.peekByte(0) == '#' {
+		for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+			l.pos++
+		}
+	}
+	start := l.pos
+	tok, err := l.scan()
+	tok.pos = start
+	return tok, err
+}
+
+// scan - лексема с текущей позиции после пропуска пробелов
+func (l *lexer) scan() (token, error) {
+	if l.pos >= len(l.src) {
+		if len(l.heredocs) > 0 {
+			return token{}, errIncomplete
+		}
+		return token{kind: tokEOF}, nil
+	}
+
+	switch l.src[l.pos] {
+	case '\n':
+		l.pos++
+		if err := l.hereDocs(); err != nil {
+			return token{}, err
+		}
+		return token{kind: tokNewline}, nil
+	case ';':
+		if l.peekByte(1) == ';' {
+			l.pos += 2
+			return token{kind: tokDSemi}, nil
+		}
+		l.pos++
+		return token{kind: tokSemi}, nil
+	case '(':
+		l.pos++
+		return token{kind: tokLParen}, nil
+	case ')':
+		l.pos++
+		return token{kind: tokRParen}, nil
+	case '|':
+		if l.peekByte(1) == '|' {
+			l.pos += 2
+			return token{kind: tokOr}, nil
+		}
+		l.pos++
+		return token{kind: tokPipe}, nil
+	case '&':
+		switch l.peekByte(1) {
+		case '&':
+			l.pos += 2
+			return token{kind: tokAnd}, nil
+		case '>':
+			l.pos += 2
+			return token{kind: tokAndGreat, fd: -1}, nil
+		}
+		l.pos++
+		return token{kind: tokAmp}, nil
+	case '<', '>':
+		return l.redirect(-1), nil
+	}
+
+	word, err := l.word()
+	if err != nil {
+		return token{}, err
+	}
+	if c := l.peekByte(0); (c == '<' || c == '>') && isNumber(word) {
+		return l.redirect(atoi(word[0].Text)), nil
+	}
+	return token{kind: tokWord, word: word}, nil
+}
+
+// redirect - оператор перенаправления с текущей позиции
+func (l *lexer) redirect(fd int) token {
+	t := token{fd: fd}
+	if l.src[l.pos] == '<' {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], "<<<"):
+			l.pos += 3
+			t.kind = tokTLess
+		case strings.HasPrefix(l.src[l.pos:], "<<-"):
+			l.pos += 3
+			t.kind = tokDLessDash
+		case strings.HasPrefix(l.src[l.pos:], "<<"):
+			l.pos += 2
+			t.kind = tokDLess
+		default:
+			l.pos++
+			t.kind = tokLess
+		}
+		return t
+	}
+	switch l.peekByte(1) {
+	case '>':
+		l.pos += 2
+		t.kind = tokDGreat
+	case '&':
+		l.pos += 2
+		t.kind = tokGreatAnd
+	default:
+		l.pos++
+		t.kind = tokGreat
+	}
+	return t
+}
+
+// isNumber - слово из одних цифр без кавычек (номер дескриптора)
+func isNumber(w Word) bool {
+	if len(w) != 1 || w[0].Quoted || w[0].isSubst() || w[0].Text == "" {
+		return false
+	}
+	for i := 0; i < len(w[0].Text); i++ {
+		if w[0].Text[i] < '0' || w[0].Text[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// atoi - число из строки цифр
+func atoi(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		n = n*10 + int(s[i]-'0')
+	}
+	return n
+}
+
+// word - чтение слова с учётом кавычек и экранирования
+func (l *lexer) word() (Word, error) {
+	var w Word
+	err := l.parts(&w, isMeta, false)
+	return w, err
+}
+
+// parts - чтение частей слова до символа, на котором stop вернёт true.
+// inDouble - чтение внутри двойных кавычек: там обратный слэш
+// экранирует только $ ` " \ и перевод строки
+func (l *lexer) parts(w *Word, stop func(byte) bool, inDouble bool) error {
+	escapes := "$`\"\\\n"
+	if l.heredoc {
+		escapes = "$`\\\n"
+	}
+	for l.pos < len(l.src) && !stop(l.src[l.pos]) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\'' && !inDouble:
+			end := strings.IndexByte(l.src[l.pos+1:], '\'')
+			if end < 0 {
+				return errIncomplete
+			}
+			w.addText(l.src[l.pos+1:l.pos+1+end], true)
+			l.pos += end + 2
+		case c == '"' && !inDouble:
+			l.pos++
+			n := len(*w)
+			if err := l.parts(w, func(c byte) bool { return c == '"' }, true); err != nil {
+				return err
+			}
+			if l.pos >= len(l.src) {
+				return errIncomplete
+			}
+			// пустые кавычки - тоже слово
+			if len(*w) == n {
+				w.addText("", true)
+			}
+			l.pos++
+		case c == '\\' && inDouble && strings.IndexByte(escapes, l.peekByte(1)) < 0:
+			w.addText(`\`, true)
+			l.pos++
+		case c == '\\':
+			switch l.peekByte(1) {
+			case 0:
+				return errIncomplete
+			case '\n':
+				if l.pos+2 == len(l.src) {
+					return errIncomplete
+				}
+			default:
+				w.addText(l.src[l.pos+1:l.pos+2], true)
+			}
+			l.pos += 2
+		case c == '$':
+			if err := l.dollar(w, inDouble); err != nil {
+				return err
+			}
+		case c == '`':
+			if err := l.backquote(w, inDouble); err != nil {
+				return err
+			}
+		default:
+			w.addText(l.src[l.pos:l.pos+1], inDouble)
+			l.pos++
+		}
+	}
+	return nil
+}
+
+// isNameByte - символ, допустимый в имени переменной
+func isNameByte(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
+
+// name - имя параметра: идентификатор, номер позиционного параметра
+// (без скобок - одна цифра) или специальный символ
+func (l *lexer) name(braced bool) string {
+	start := l.pos
+	switch c := l.peekByte(0); {
+	case isNameByte(c, true):
+		for l.pos < len(l.src) && isNameByte(l.src[l.pos], false) {
+			l.pos++
+		}
+	case c >= '0' && c <= '9':
+		l.pos++
+		for braced && l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+			l.pos++
+		}
+	case c != 0 && strings.IndexByte("?$!#@*", c) >= 0:
+		l.pos++
+	}
+	return l.src[start:l.pos]
+}
+
+// dollar - подстановка параметра $NAME, ${...} или команды $(...);
+// одиночный $ - просто символ
+func (l *lexer) dollar(w *Word, inDouble bool) error {
+	l.pos++
+	if l.peekByte(0) == '(' {
+		return l.subst(w, inDouble)
+	}
+	if l.peekByte(0) != '{' {
+		name := l.name(false)
+		if name == "" {
+			w.addText("$", inDouble)
+			return nil
+		}
+		*w = append(*w, WordPart{Param: &ParamExp{Name: name}, Quoted: inDouble})
+		return nil
+	}
+
+	l.pos++
+	param := &ParamExp{Name: l.name(true)}
+	if param.Name == "" {
+		return errBadSubst
+	}
+	if l.peekByte(0) == ':' {
+		param.Op = ":"
+		l.pos++
+	}
+	switch c := l.peekByte(0); c {
+	case '}':
+		if param.Op != "" {
+			return errBadSubst
+		}
+	case '-', '=', '+':
+		param.Op += string(c)
+		l.pos++
+		if err := l.parts(&param.Word, func(c byte) bool { return c == '}' }, inDouble); err != nil {
+			return err
+		}
+	case 0:
+		return errIncomplete
+	default:
+		return errBadSubst
+	}
+	if l.pos >= len(l.src) {
+		return errIncomplete
+	}
+	l.pos++
+	*w = append(*w, WordPart{Param: param, Quoted: inDouble})
+	return nil
+}
+
+// subst - подстановка команды $(...): текущий символ - (. Вложенная
+// команда разбирается тем же парсером до парной )
+func (l *lexer) subst(w *Word, inDouble bool) error {
+	start := l.pos + 1
+	p := &parser{lex: &lexer{src: l.src, pos: start, aliases: l.aliases, expanding: l.expanding}}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	list, err := p.list()
+	if err != nil {
+		return err
+	}
+	if p.tok.kind != tokRParen {
+		return p.unexpected()
+	}
+	// алиасы внутри подстановки меняют исходную строку
+	l.src, l.pos = p.lex.src, p.lex.pos
+	text := strings.TrimSpace(l.src[start : l.pos-1])
+	*w = append(*w, WordPart{Cmd: &CmdSubst{List: list, Text: text}, Quoted: inDouble})
+	return nil
+}
+
+// backquote - подстановка команды `...`: внутри обратный слэш
+// экранирует только $ ` и \
+func (l *lexer) backquote(w *Word, inDouble bool) error {
+	var b strings.Builder
+	for l.pos++; ; l.pos++ {
+		c := l.peekByte(0)
+		switch {
+		case c == 0:
+			return errIncomplete
+		case c == '`':
+			l.pos++
+			list, err := parse(b.String(), l.aliases)
+			if errors.Is(err, errIncomplete) {
+				return fmt.Errorf("syntax error: unexpected end of command substitution")
+			}
+			if err != nil {
+				return err
+			}
+			text := strings.TrimSpace(b.String())
+			*w = append(*w, WordPart{Cmd: &CmdSubst{List: list, Text: text}, Quoted: inDouble})
+			return nil
+		case c == '\\' && (strings.IndexByte("$`\\", l.peekByte(1)) >= 0 || inDouble && l.peekByte(1) == '"'):
+			l.pos++
+			b.WriteByte(l.src[l.pos])
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// hereDocs - чтение тел here-документов, начатых в только что
+// закончившейся строке: строки до разделителя (для <<- без ведущих
+// табуляций). Если разделитель был в кавычках, тело не раскрывается,
+// иначе в нём работают подстановки и экранирование \$ \` \\
+func (l *lexer) hereDocs() error {
+	for _, r := range l.heredocs {
+		delim := r.Target.String()
+		var body strings.Builder
+		for {
+			if l.pos >= len(l.src) {
+				return errIncomplete
+			}
+			line, rest, _ := strings.Cut(l.src[l.pos:], "\n")
+			l.pos = len(l.src) - len(rest)
+			if r.Op == tokDLessDash {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == delim {
+				break
+			}
+			body.WriteString(line + "\n")
+		}
+
+		quoted := false
+		for _, p := range r.Target {
+			quoted = quoted || p.Quoted
+		}
+		if quoted {
+			r.Body = Word{{Text: body.String(), Quoted: true}}
+			continue
+		}
+		sub := &lexer{src: body.String(), aliases: l.aliases, expanding: make(map[string]int), heredoc: true}
+		if err := sub.parts(&r.Body, func(byte) bool { return false }, true); err != nil {
+			return err
+		}
+	}
+	l.heredocs = nil
+	return nil
+}
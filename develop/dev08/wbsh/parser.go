@@ -0,0 +1,684 @@
+package wbsh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node - узел синтаксического дерева: *List, *AndOr, *Pipeline,
+// *Not, *Command, составные команды, *Subshell и *FuncDecl
+type Node interface{}
+
+// Redirect - перенаправление ввода-вывода вида [Fd]Op Target. Для
+// here-документа Target - разделитель, Body - тело
+type Redirect struct {
+	Fd     int
+	Op     tokKind
+	Target Word
+	Body   Word
+}
+
+// Assign - присваивание NAME=Value перед командой
+type Assign struct {
+	Name  string
+	Value Word
+}
+
+// Command - простая команда: присваивания, слова и перенаправления
+type Command struct {
+	Assigns []*Assign
+	Args    []Word
+	Redirs  []*Redirect
+}
+
+// Pipeline - команды, связанные через |
+type Pipeline struct {
+	Cmds []Node
+}
+
+// Not - отрицание кода возврата конвейера: ! X
+type Not struct {
+	X Node
+}
+
+// IfClause - if Cond; then Then; [elif ...;] [else ...;] fi.
+// Else - *IfClause для elif, *List для else или nil
+type IfClause struct {
+	Cond, Then *List
+	Else       Node
+}
+
+// WhileClause - while Cond; do Body; done (until при Until)
+type WhileClause struct {
+	Until      bool
+	Cond, Body *List
+}
+
+// ForClause - for Name in Words; do Body; done. Без in (In == false)
+// перебираются позиционные параметры
+type ForClause struct {
+	Name  string
+	In    bool
+	Words []Word
+	Body  *List
+}
+
+// CaseItem - ветка case: pattern | pattern) Body ;;
+type CaseItem struct {
+	Patterns []Word
+	Body     *List
+}
+
+// CaseClause - case Word in ... esac
+type CaseClause struct {
+	Word  Word
+	Items []*CaseItem
+}
+
+// Block - группа команд { Body; }
+type Block struct {
+	Body *List
+}
+
+// Subshell - команды в отдельном шелле ( Body ): изменения каталога
+// и переменных не видны снаружи
+type Subshell struct {
+	Body *List
+}
+
+// Redirected - составная команда с перенаправлениями: { ...; } > file
+type Redirected struct {
+	Node   Node
+	Redirs []*Redirect
+}
+
+// FuncDecl - объявление функции name() { ...; }
+type FuncDecl struct {
+	Name string
+	Body Node
+}
+
+// AndOr - условное выполнение X && Y или X || Y
+type AndOr struct {
+	Op   tokKind
+	X, Y Node
+}
+
+// Stmt - элемент списка команд, Background - запуск в фоне (&),
+// Text - исходный текст команды для таблицы заданий
+type Stmt struct {
+	Node       Node
+	Background bool
+	Text       string
+}
+
+// List - последовательность команд через ; & и перевод строки
+type List struct {
+	Stmts []*Stmt
+}
+
+// parser - разбор лексем в синтаксическое дерево
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// Parse - разбор командной строки
+func Parse(src string) (*List, error) {
+	return parse(src, nil)
+}
+
+// parse - разбор командной строки с подстановкой алиасов
+func parse(src string, aliases map[string]string) (*List, error) {
+	p := &parser{lex: &lexer{src: src, aliases: aliases, expanding: make(map[string]int)}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	list, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return list, nil
+}
+
+// advance - переход к следующей лексеме
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// unexpected - ошибка разбора на текущей лексеме
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return errIncomplete
+	}
+	return fmt.Errorf("syntax error near unexpected token '%v'", p.tok)
+}
+
+// skipNewlines - пропуск пустых строк
+func (p *parser) skipNewlines() error {
+	for p.tok.kind == tokNewline {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isRedirect - лексема - оператор перенаправления
+func isRedirect(kind tokKind) bool {
+	switch kind {
+	case tokLess, tokGreat, tokDGreat, tokGreatAnd, tokAndGreat, tokDLess, tokDLessDash, tokTLess:
+		return true
+	}
+	return false
+}
+
+// closers - зарезервированные слова, завершающие список команд
+var closers = map[string]bool{
+	"then": true, "elif": true, "else": true, "fi": true,
+	"do": true, "done": true, "esac": true, "}": true,
+}
+
+// isReserved - текущая лексема - зарезервированное слово word.
+// Слово не должно быть в кавычках или содержать подстановки
+func (p *parser) isReserved(word string) bool {
+	w := p.tok.word
+	return p.tok.kind == tokWord && len(w) == 1 && !w[0].Quoted && !w[0].isSubst() && w[0].Text == word
+}
+
+// startsCommand - с текущей лексемы может начинаться команда
+func (p *parser) startsCommand() bool {
+	switch {
+	case isRedirect(p.tok.kind), p.tok.kind == tokLParen:
+		return true
+	case p.tok.kind != tokWord:
+		return false
+	}
+	for word := range closers {
+		if p.isReserved(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// expect - пропуск обязательного зарезервированного слова
+func (p *parser) expect(word string) error {
+	if !p.isReserved(word) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+// list - последовательность and-or цепочек
+func (p *parser) list() (*List, error) {
+	list := &List{}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	for p.startsCommand() {
+		start := p.tok.pos
+		node, err := p.andOr()
+		if err != nil {
+			return nil, err
+		}
+		stmt := &Stmt{Node: node, Text: strings.TrimSpace(p.lex.src[start:p.tok.pos])}
+		list.Stmts = append(list.Stmts, stmt)
+
+		switch p.tok.kind {
+		case tokAmp:
+			stmt.Background = true
+		case tokSemi, tokNewline:
+		default:
+			return list, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// body - непустой список команд внутри составной команды
+func (p *parser) body() (*List, error) {
+	list, err := p.list()
+	if err == nil && len(list.Stmts) == 0 {
+		err = p.unexpected()
+	}
+	return list, err
+}
+
+// andOr - конвейеры, связанные && и ||
+func (p *parser) andOr() (Node, error) {
+	x, err := p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokAnd || p.tok.kind == tokOr {
+		op := p.tok.kind
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		y, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		x = &AndOr{Op: op, X: x, Y: y}
+	}
+	return x, nil
+}
+
+// pipeline - команды, связанные |, возможно с отрицанием !
+func (p *parser) pipeline() (Node, error) {
+	if p.isReserved("!") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	}
+	cmd, err := p.command()
+	if err != nil {
+		return nil, err
+	}
+	cmds := []Node{cmd}
+	for p.tok.kind == tokPipe {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		if cmd, err = p.command(); err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	if len(cmds) == 1 {
+		return cmds[0], nil
+	}
+	return &Pipeline{Cmds: cmds}, nil
+}
+
+// alias - подстановка алиаса вместо первого слова команды. Слово
+// не должно быть в кавычках; внутри своего текста алиас не
+// раскрывается, поэтому alias ls='ls -F' не зацикливается
+func (p *parser) alias() error {
+	for p.tok.kind == tokWord {
+		w := p.tok.word
+		if len(w) != 1 || w[0].Quoted || w[0].isSubst() {
+			return nil
+		}
+		name := w[0].Text
+		if _, ok := p.lex.aliases[name]; !ok || p.lex.inAlias(name, p.tok.pos) {
+			return nil
+		}
+		p.lex.expandAlias(name, p.tok.pos, p.lex.pos)
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// command - простая или составная команда, объявление функции
+func (p *parser) command() (Node, error) {
+	if err := p.alias(); err != nil {
+		return nil, err
+	}
+	var (
+		node Node
+		err  error
+	)
+	switch {
+	case p.isReserved("if"):
+		node, err = p.ifClause()
+	case p.isReserved("while"), p.isReserved("until"):
+		node, err = p.whileClause()
+	case p.isReserved("for"):
+		node, err = p.forClause()
+	case p.isReserved("case"):
+		node, err = p.caseClause()
+	case p.isReserved("{"):
+		node, err = p.block()
+	case p.tok.kind == tokLParen:
+		node, err = p.subshell()
+	default:
+		return p.simple()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.redirected(node)
+}
+
+// redirected - перенаправления после составной команды
+func (p *parser) redirected(node Node) (Node, error) {
+	var redirs []*Redirect
+	for isRedirect(p.tok.kind) {
+		r, err := p.redirect()
+		if err != nil {
+			return nil, err
+		}
+		redirs = append(redirs, r)
+	}
+	if len(redirs) == 0 {
+		return node, nil
+	}
+	return &Redirected{Node: node, Redirs: redirs}, nil
+}
+
+// ifClause - if ... then ... [elif ... then ...] [else ...] fi
+func (p *parser) ifClause() (*IfClause, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var (
+		clause = &IfClause{}
+		err    error
+	)
+	if clause.Cond, err = p.body(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("then"); err != nil {
+		return nil, err
+	}
+	if clause.Then, err = p.body(); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isReserved("elif"):
+		clause.Else, err = p.ifClause()
+		return clause, err
+	case p.isReserved("else"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if clause.Else, err = p.body(); err != nil {
+			return nil, err
+		}
+	}
+	return clause, p.expect("fi")
+}
+
+// doGroup - do ... done
+func (p *parser) doGroup() (*List, error) {
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	body, err := p.body()
+	if err != nil {
+		return nil, err
+	}
+	return body, p.expect("done")
+}
+
+// whileClause - while/until ... do ... done
+func (p *parser) whileClause() (*WhileClause, error) {
+	clause := &WhileClause{Until: p.isReserved("until")}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if clause.Cond, err = p.body(); err != nil {
+		return nil, err
+	}
+	clause.Body, err = p.doGroup()
+	return clause, err
+}
+
+// forClause - for NAME [in WORDS...]; do ... done
+func (p *parser) forClause() (*ForClause, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokWord || !isName(p.tok.word.String()) {
+		return nil, p.unexpected()
+	}
+	clause := &ForClause{Name: p.tok.word.String()}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if p.isReserved("in") {
+		clause.In = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for p.tok.kind == tokWord {
+			clause.Words = append(clause.Words, p.tok.word)
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.tok.kind == tokSemi {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	var err error
+	clause.Body, err = p.doGroup()
+	return clause, err
+}
+
+// caseClause - case WORD in [(]pattern[|pattern]) ... ;; ... esac
+func (p *parser) caseClause() (*CaseClause, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokWord {
+		return nil, p.unexpected()
+	}
+	clause := &CaseClause{Word: p.tok.word}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	for !p.isReserved("esac") {
+		item := &CaseItem{}
+		if p.tok.kind == tokLParen {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		for {
+			if p.tok.kind != tokWord {
+				return nil, p.unexpected()
+			}
+			item.Patterns = append(item.Patterns, p.tok.word)
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokPipe {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.unexpected()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err error
+		if item.Body, err = p.list(); err != nil {
+			return nil, err
+		}
+		clause.Items = append(clause.Items, item)
+
+		if p.tok.kind != tokDSemi {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+	return clause, p.expect("esac")
+}
+
+// block - { ...; }
+func (p *parser) block() (*Block, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	body, err := p.body()
+	if err != nil {
+		return nil, err
+	}
+	return &Block{Body: body}, p.expect("}")
+}
+
+// subshell - ( ... )
+func (p *parser) subshell() (*Subshell, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	body, err := p.body()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokRParen {
+		return nil, p.unexpected()
+	}
+	return &Subshell{Body: body}, p.advance()
+}
+
+// funcDecl - объявление функции: имя уже прочитано, текущая лексема - (
+func (p *parser) funcDecl(name Word) (*FuncDecl, error) {
+	if !isName(name.String()) || len(name) != 1 || name[0].Quoted {
+		return nil, fmt.Errorf("'%v': not a valid identifier", name)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokRParen {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokWord && p.tok.kind != tokLParen {
+		return nil, p.unexpected()
+	}
+	body, err := p.command()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := body.(*Command); ok {
+		return nil, fmt.Errorf("syntax error: function body must be a compound command")
+	}
+	return &FuncDecl{Name: name.String(), Body: body}, nil
+}
+
+// simple - простая команда: присваивания, слова и перенаправления
+func (p *parser) simple() (Node, error) {
+	cmd := &Command{}
+	for {
+		switch {
+		case p.tok.kind == tokWord:
+			if a := assignment(p.tok.word); a != nil && len(cmd.Args) == 0 {
+				cmd.Assigns = append(cmd.Assigns, a)
+			} else {
+				cmd.Args = append(cmd.Args, p.tok.word)
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind == tokLParen && len(cmd.Args) == 1 && len(cmd.Assigns) == 0 && len(cmd.Redirs) == 0 {
+				return p.funcDecl(cmd.Args[0])
+			}
+		case isRedirect(p.tok.kind):
+			r, err := p.redirect()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Redirs = append(cmd.Redirs, r)
+		case len(cmd.Assigns) == 0 && len(cmd.Args) == 0 && len(cmd.Redirs) == 0:
+			return nil, p.unexpected()
+		default:
+			return cmd, nil
+		}
+	}
+}
+
+// redirect - оператор перенаправления и его цель
+func (p *parser) redirect() (*Redirect, error) {
+	r := &Redirect{Fd: p.tok.fd, Op: p.tok.kind}
+	if r.Fd < 0 {
+		switch r.Op {
+		case tokLess, tokDLess, tokDLessDash, tokTLess:
+			r.Fd = 0
+		case tokGreat, tokDGreat, tokGreatAnd:
+			r.Fd = 1
+		}
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokWord {
+		return nil, p.unexpected()
+	}
+	r.Target = p.tok.word
+	if r.Op == tokDLess || r.Op == tokDLessDash {
+		// тело прочитает лексер после конца строки
+		p.lex.heredocs = append(p.lex.heredocs, r)
+	}
+	return r, p.advance()
+}
+
+// assignment - разбор слова вида NAME=value, nil если это не присваивание
+func assignment(w Word) *Assign {
+	if len(w) == 0 || w[0].Quoted || w[0].isSubst() {
+		return nil
+	}
+	name, value, ok := strings.Cut(w[0].Text, "=")
+	if !ok || !isName(name) {
+		return nil
+	}
+	a := &Assign{Name: name}
+	if value != "" {
+		a.Value = Word{{Text: value}}
+	}
+	a.Value = append(a.Value, w[1:]...)
+	return a
+}
@@ -0,0 +1,242 @@
+package wbsh
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	word := func(parts ...WordPart) Word { return parts }
+	lit := func(s string) WordPart { return WordPart{Text: s} }
+	quoted := func(s string) WordPart { return WordPart{Text: s, Quoted: true} }
+	cmd := func(args ...Word) *Command { return &Command{Args: args} }
+
+	testcases := []struct {
+		name  string
+		input string
+		want  *List
+		err   error
+	}{
+		{
+			name:  "Quoted pipe is a part of the word",
+			input: `echo "a | b" 'c  d'`,
+			want: &List{Stmts: []*Stmt{{Node: cmd(
+				word(lit("echo")), word(quoted("a | b")), word(quoted("c  d")),
+			), Text: `echo "a | b" 'c  d'`}}},
+		},
+		{
+			name:  "Escapes",
+			input: `echo a\ b "x\"y\n"`,
+			want: &List{Stmts: []*Stmt{{Node: cmd(
+				word(lit("echo")), word(lit("a"), quoted(" "), lit("b")), word(quoted(`x"y\n`)),
+			), Text: `echo a\ b "x\"y\n"`}}},
+		},
+		{
+			name:  "Pipeline, and-or list and background",
+			input: "ps | grep go && echo ok || echo fail; sleep 1 &",
+			want: &List{Stmts: []*Stmt{
+				{Node: &AndOr{
+					Op: tokOr,
+					X: &AndOr{
+						Op: tokAnd,
+						X: &Pipeline{Cmds: []Node{
+							cmd(word(lit("ps"))),
+							cmd(word(lit("grep")), word(lit("go"))),
+						}},
+						Y: cmd(word(lit("echo")), word(lit("ok"))),
+					},
+					Y: cmd(word(lit("echo")), word(lit("fail"))),
+				}, Text: "ps | grep go && echo ok || echo fail"},
+				{Node: cmd(word(lit("sleep")), word(lit("1"))), Background: true, Text: "sleep 1"},
+			}},
+		},
+		{
+			name:  "Redirections",
+			input: "cmd <in 2>&1 >>log",
+			want: &List{Stmts: []*Stmt{{Node: &Command{
+				Args: []Word{word(lit("cmd"))},
+				Redirs: []*Redirect{
+					{Fd: 0, Op: tokLess, Target: word(lit("in"))},
+					{Fd: 2, Op: tokGreatAnd, Target: word(lit("1"))},
+					{Fd: 1, Op: tokDGreat, Target: word(lit("log"))},
+				},
+			}, Text: "cmd <in 2>&1 >>log"}}},
+		},
+		{
+			name:  "Assignments and parameters",
+			input: `X=1 echo $Y "${Z:-a b}"`,
+			want: &List{Stmts: []*Stmt{{Node: &Command{
+				Assigns: []*Assign{{Name: "X", Value: word(lit("1"))}},
+				Args: []Word{
+					word(lit("echo")),
+					word(WordPart{Param: &ParamExp{Name: "Y"}}),
+					word(WordPart{Param: &ParamExp{Name: "Z", Op: ":-", Word: word(quoted("a b"))}, Quoted: true}),
+				},
+			}, Text: `X=1 echo $Y "${Z:-a b}"`}}},
+		},
+		{
+			name:  "If with elif and else",
+			input: "if a; then b; elif c; then d; else e; fi",
+			want: &List{Stmts: []*Stmt{{Node: &IfClause{
+				Cond: &List{Stmts: []*Stmt{{Node: cmd(word(lit("a"))), Text: "a"}}},
+				Then: &List{Stmts: []*Stmt{{Node: cmd(word(lit("b"))), Text: "b"}}},
+				Else: &IfClause{
+					Cond: &List{Stmts: []*Stmt{{Node: cmd(word(lit("c"))), Text: "c"}}},
+					Then: &List{Stmts: []*Stmt{{Node: cmd(word(lit("d"))), Text: "d"}}},
+					Else: &List{Stmts: []*Stmt{{Node: cmd(word(lit("e"))), Text: "e"}}},
+				},
+			}, Text: "if a; then b; elif c; then d; else e; fi"}}},
+		},
+		{
+			name:  "For loop and reserved word as an argument",
+			input: "for x in a b\ndo echo done; done",
+			want: &List{Stmts: []*Stmt{{Node: &ForClause{
+				Name:  "x",
+				In:    true,
+				Words: []Word{word(lit("a")), word(lit("b"))},
+				Body: &List{Stmts: []*Stmt{{
+					Node: cmd(word(lit("echo")), word(lit("done"))), Text: "echo done",
+				}}},
+			}, Text: "for x in a b\ndo echo done; done"}}},
+		},
+		{
+			name:  "Case",
+			input: "case $x in a|b) one;; *) ;; esac",
+			want: &List{Stmts: []*Stmt{{Node: &CaseClause{
+				Word: word(WordPart{Param: &ParamExp{Name: "x"}}),
+				Items: []*CaseItem{
+					{
+						Patterns: []Word{word(lit("a")), word(lit("b"))},
+						Body:     &List{Stmts: []*Stmt{{Node: cmd(word(lit("one"))), Text: "one"}}},
+					},
+					{Patterns: []Word{word(lit("*"))}, Body: &List{}},
+				},
+			}, Text: "case $x in a|b) one;; *) ;; esac"}}},
+		},
+		{
+			name:  "Function with redirected body",
+			input: "f() { ! a; } >out",
+			want: &List{Stmts: []*Stmt{{Node: &FuncDecl{
+				Name: "f",
+				Body: &Redirected{
+					Node: &Block{Body: &List{Stmts: []*Stmt{{
+						Node: &Not{X: cmd(word(lit("a")))}, Text: "! a",
+					}}}},
+					Redirs: []*Redirect{{Fd: 1, Op: tokGreat, Target: word(lit("out"))}},
+				},
+			}, Text: "f() { ! a; } >out"}}},
+		},
+		{
+			name:  "Command substitution",
+			input: "echo \"$(a | b)\"`c \\`d\\``",
+			want: &List{Stmts: []*Stmt{{Node: cmd(word(lit("echo")), word(
+				WordPart{Cmd: &CmdSubst{List: &List{Stmts: []*Stmt{{
+					Node: &Pipeline{Cmds: []Node{cmd(word(lit("a"))), cmd(word(lit("b")))}}, Text: "a | b",
+				}}}, Text: "a | b"}, Quoted: true},
+				WordPart{Cmd: &CmdSubst{List: &List{Stmts: []*Stmt{{
+					Node: cmd(word(lit("c")), word(WordPart{Cmd: &CmdSubst{List: &List{Stmts: []*Stmt{{
+						Node: cmd(word(lit("d"))), Text: "d",
+					}}}, Text: "d"}})), Text: "c `d`",
+				}}}, Text: "c `d`"}},
+			)), Text: "echo \"$(a | b)\"`c \\`d\\``"}}},
+		},
+		{
+			name:  "Subshell",
+			input: "(cd /; a) >out",
+			want: &List{Stmts: []*Stmt{{Node: &Redirected{
+				Node: &Subshell{Body: &List{Stmts: []*Stmt{
+					{Node: cmd(word(lit("cd")), word(lit("/"))), Text: "cd /"},
+					{Node: cmd(word(lit("a"))), Text: "a"},
+				}}},
+				Redirs: []*Redirect{{Fd: 1, Op: tokGreat, Target: word(lit("out"))}},
+			}, Text: "(cd /; a) >out"}}},
+		},
+		{
+			name:  "Here-documents",
+			input: "cat <<EOF <<-'END' <<<$y\n$x \\$x\nEOF\n\tliteral $x\n\tEND\n",
+			want: &List{Stmts: []*Stmt{{Node: &Command{
+				Args: []Word{word(lit("cat"))},
+				Redirs: []*Redirect{
+					{Fd: 0, Op: tokDLess, Target: word(lit("EOF")), Body: word(
+						WordPart{Param: &ParamExp{Name: "x"}, Quoted: true}, quoted(" $x\n"),
+					)},
+					{Fd: 0, Op: tokDLessDash, Target: word(quoted("END")), Body: word(quoted("literal $x\n"))},
+					{Fd: 0, Op: tokTLess, Target: word(WordPart{Param: &ParamExp{Name: "y"}})},
+				},
+			}, Text: "cat <<EOF <<-'END' <<<$y"}}},
+		},
+		{
+			name:  "Unfinished here-document",
+			input: "cat <<EOF\nline\n",
+			err:   errIncomplete,
+		},
+		{
+			name:  "Unclosed command substitution",
+			input: "echo $(a",
+			err:   errIncomplete,
+		},
+		{
+			name:  "Unfinished while",
+			input: "while true; do",
+			err:   errIncomplete,
+		},
+		{
+			name:  "Unclosed quote",
+			input: `echo "abc`,
+			err:   errIncomplete,
+		},
+		{
+			name:  "Trailing pipe",
+			input: "ps |",
+			err:   errIncomplete,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.input)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err: %v, want: %v", err, tc.err)
+			}
+			if tc.err == nil && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"; ls", "fi", "if; then a; fi", "case x in a b) c;; esac", "()", "echo `a (`"} {
+		if _, err := Parse(input); err == nil || errors.Is(err, errIncomplete) {
+			t.Errorf("%q: syntax error expected, got: %v", input, err)
+		}
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	aliases := map[string]string{"ls": "ls -F", "ll": "ls -l", "e": "echo", "both": "ll; e"}
+	testcases := []struct {
+		input string
+		want  string
+	}{
+		{input: "ll /tmp", want: "ls -F -l /tmp"},
+		{input: "e ll | e 'll'", want: "echo ll | echo 'll'"},
+		{input: "both x", want: "ls -F -l; echo x"},
+		{input: `"ll"; \ll`, want: `"ll"; \ll`},
+		{input: "x=$(ll)", want: "x=$(ls -F -l)"},
+		{input: "if e; then ll; fi", want: "if echo; then ls -F -l; fi"},
+	}
+
+	for _, tc := range testcases {
+		got, err := parse(tc.input, aliases)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.input, err)
+		}
+		want, err := Parse(tc.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got: %#v, want: %#v", tc.input, got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package wbsh
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// patternMeta - символы, имеющие особый смысл в шаблоне
+const patternMeta = `*?[]\`
+
+// matchPattern - сопоставление строки с шаблоном: * - любая строка,
+// ? - любой символ, [...] - класс символов ([!...] или [^...] -
+// отрицание), \ экранирует следующий символ
+func matchPattern(pattern, s string) bool {
+	for pattern != "" {
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+			for i := range s {
+				if matchPattern(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+			_, n := utf8.DecodeRuneInString(s)
+			pattern, s = pattern[1:], s[n:]
+			continue
+		case '[':
+			if s == "" {
+				return false
+			}
+			r, n := utf8.DecodeRuneInString(s)
+			if matched, rest, ok := matchClass(pattern[1:], r); ok {
+				if !matched {
+					return false
+				}
+				pattern, s = rest, s[n:]
+				continue
+			}
+			// незакрытая [ сравнивается как обычный символ
+		}
+		var want rune
+		want, pattern = nextRune(pattern)
+		r, n := utf8.DecodeRuneInString(s)
+		if s == "" || r != want {
+			return false
+		}
+		s = s[n:]
+	}
+	return s == ""
+}
+
+// nextRune - очередной символ шаблона с учётом экранирования
+func nextRune(pattern string) (rune, string) {
+	if pattern[0] == '\\' && len(pattern) > 1 {
+		pattern = pattern[1:]
+	}
+	r, n := utf8.DecodeRuneInString(pattern)
+	return r, pattern[n:]
+}
+
+// matchClass - проверка символа r по классу [...], pattern - текст
+// после [. Возвращает остаток шаблона после ] и ok == false, если
+// класс не закрыт
+func matchClass(pattern string, r rune) (matched bool, rest string, ok bool) {
+	negate := false
+	if pattern != "" && (pattern[0] == '!' || pattern[0] == '^') {
+		negate = true
+		pattern = pattern[1:]
+	}
+	for i := 0; ; i++ {
+		if pattern == "" {
+			return false, "", false
+		}
+		// ] сразу после [ или [! - обычный символ класса
+		if pattern[0] == ']' && i > 0 {
+			return matched != negate, pattern[1:], true
+		}
+		var lo, hi rune
+		lo, pattern = nextRune(pattern)
+		hi = lo
+		if len(pattern) > 1 && pattern[0] == '-' && pattern[1] != ']' {
+			hi, pattern = nextRune(pattern[1:])
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+}
+
+// expandPattern - раскрытие слова в шаблон: символы в кавычках
+// экранируются и сравниваются буквально
+func (s *Shell) expandPattern(w Word) string {
+	var b strings.Builder
+	for _, p := range w {
+		text := p.Text
+		if p.isSubst() {
+			text = s.substText(p)
+		}
+		if p.Quoted {
+			text = escapePattern(text)
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// escapePattern - экранирование символов шаблона, чтобы они
+// сравнивались буквально
+func escapePattern(text string) string {
+	if !strings.ContainsAny(text, patternMeta) {
+		return text
+	}
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(patternMeta, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// hasMeta - в шаблоне есть неэкранированные * ? [
+func hasMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
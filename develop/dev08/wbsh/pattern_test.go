@@ -0,0 +1,91 @@
+package wbsh
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	testcases := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{pattern: "*", s: "", want: true},
+		{pattern: "a*c", s: "abbc", want: true},
+		{pattern: "a*c", s: "abcd", want: false},
+		{pattern: "?б?", s: "абв", want: true},
+		{pattern: "[a-c]x", s: "bx", want: true},
+		{pattern: "[!a-c]x", s: "bx", want: false},
+		{pattern: "[^a-c]x", s: "dx", want: true},
+		{pattern: "[]]", s: "]", want: true},
+		{pattern: `\*`, s: "*", want: true},
+		{pattern: `\*`, s: "a", want: false},
+		{pattern: "[ab", s: "[ab", want: true},
+	}
+
+	for _, tc := range testcases {
+		if got := matchPattern(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want: %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", ".c.go", "d/e.go", "d/f/g.go", "d/f/h.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	sh := NewShell(io.Discard, strings.NewReader(""))
+	sh.vars = map[string]*variable{"HOME": {value: "/home/u"}, "P": {value: "*.go"}}
+	testcases := []struct {
+		input string
+		want  []string
+	}{
+		{input: `*.go`, want: []string{"a.go", "b.go"}},
+		{input: `.*`, want: []string{".c.go"}},
+		{input: `"*".go`, want: []string{"*.go"}},
+		{input: `$P`, want: []string{"a.go", "b.go"}},
+		{input: `"$P"`, want: []string{"*.go"}},
+		{input: `*/`, want: []string{"d/"}},
+		{input: `d/*/?.go`, want: []string{"d/f/g.go"}},
+		{input: `**/*.go`, want: []string{"a.go", "b.go", "d/e.go", "d/f/g.go"}},
+		{input: `d/**`, want: []string{"d/e.go", "d/f", "d/f/g.go", "d/f/h.txt"}},
+		{input: `[!a].go`, want: []string{"b.go"}},
+		{input: `*.rs`, want: []string{"*.rs"}},
+		{input: `~/x`, want: []string{"/home/u/x"}},
+		{input: `~`, want: []string{"/home/u"}},
+		{input: `"~"`, want: []string{"~"}},
+		{input: `~$P`, want: []string{"~*.go"}},
+	}
+
+	for _, tc := range testcases {
+		list, err := Parse("echo " + tc.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := sh.expandWord(list.Stmts[0].Node.(*Command).Args[1])
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got: %q, want: %q", tc.input, got, tc.want)
+		}
+	}
+}
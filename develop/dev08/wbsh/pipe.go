@@ -0,0 +1,215 @@
+package wbsh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// stage - запущенная команда конвейера: внешний процесс задания или
+// встроенная команда, работающая в отдельной горутине
+type stage struct {
+	job  *Job
+	proc *process
+	done chan int
+}
+
+// wait - ожидание завершения команды и её код возврата
+func (st *stage) wait() int {
+	if st.proc == nil {
+		return <-st.done
+	}
+	return st.job.waitProc(st.proc)
+}
+
+// finished - команда, которая уже завершилась с кодом status
+func finished(status int) *stage {
+	st := &stage{done: make(chan int, 1)}
+	st.done <- status
+	return st
+}
+
+// exitStatus - код возврата по ошибке завершения внешней команды
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// closeAll - закрытие концов каналов, которые больше не нужны
+func closeAll(closers []io.Closer) {
+	for _, c := range closers {
+		c.Close()
+	}
+}
+
+// Exec - подготовка внешней команды с заданными потоками. Команда
+// ищется по $PATH шелла, ошибку поиска вернёт cmd.Start
+func (s *Shell) Exec(args []string, std stdio) *exec.Cmd {
+	cmd := &exec.Cmd{Path: args[0], Args: args}
+	if path, err := s.LookPath(args[0]); err != nil {
+		cmd.Err = err
+	} else {
+		cmd.Path = path
+	}
+	cmd.Stdin = std.in
+	cmd.Stdout = std.out
+	cmd.Stderr = std.err
+	cmd.Env = s.Environ()
+	cmd.Dir = s.dir
+	return cmd
+}
+
+// spawn - выполнение fn в отдельной горутине, closers закрываются
+// по её завершении
+func spawn(fn func() int, closers []io.Closer) *stage {
+	st := &stage{done: make(chan int, 1)}
+	go func() {
+		defer closeAll(closers)
+		st.done <- fn()
+	}()
+	return st
+}
+
+// start - запуск одной команды конвейера. closers - концы каналов,
+// которые закрываются, как только команде они больше не нужны:
+// для внешней команды сразу после старта (у процесса свои копии),
+// для встроенной - по её завершении
+func (s *Shell) start(node Node, std stdio, closers ...io.Closer) *stage {
+	if std.job == nil {
+		std.job = newJob("", false)
+	}
+	c, ok := node.(*Command)
+	if !ok {
+		return spawn(func() int { return s.run(node, std) }, closers)
+	}
+	std, files, err := s.redirect(c.Redirs, std)
+	if err != nil {
+		closeAll(closers)
+		fmt.Fprintln(std.err, err)
+		return finished(1)
+	}
+	closers = append(closers, files...)
+
+	substs := s.substs
+	assigns := make([]string, 0, len(c.Assigns))
+	for _, a := range c.Assigns {
+		assigns = append(assigns, a.Name+"="+s.expandString(s.expandTilde(a.Value)))
+	}
+	args := s.expand(c.Args)
+	if s.intr.Load() || s.ctx.Err() != nil {
+		// Ctrl-C или конец сеанса во время подстановки команды
+		// отменяют и саму команду
+		closeAll(closers)
+		return finished(130)
+	}
+	if s.xtrace {
+		s.trace(std.err, assigns, args)
+	}
+	if len(args) == 0 {
+		// без команды присваивания меняют переменные самого шелла
+		for i, a := range c.Assigns {
+			s.Set(a.Name, strings.TrimPrefix(assigns[i], a.Name+"="))
+		}
+		closeAll(closers)
+		// код возврата - у последней подстановки команды, если она была
+		if s.substs != substs {
+			return finished(s.Status)
+		}
+		return finished(0)
+	}
+
+	// присваивания перед командой действуют только на неё
+	sh := s
+	if len(assigns) > 0 {
+		sh = s.subshell()
+		for i, a := range c.Assigns {
+			sh.vars[a.Name] = &variable{value: strings.TrimPrefix(assigns[i], a.Name+"="), exported: true}
+		}
+	}
+
+	switch {
+	case args[0] == "exec" && len(args) == 1 && !s.inSubshell:
+		// exec без команды перенаправляет вывод самого шелла,
+		// открытые файлы остаются за ним
+		s.Out, s.Err = std.out, std.err
+		return finished(0)
+	case args[0] == "exec" && !s.inSubshell:
+		status := sh.execShell(args[1:], std)
+		closeAll(closers)
+		// как в POSIX: неудавшийся exec завершает неинтерактивный шелл
+		s.exited = !s.interactive
+		return finished(status)
+	case args[0] == "exec":
+		// в подоболочке заменять весь процесс нельзя: просто запускаем команду
+		if args = args[1:]; len(args) == 0 {
+			closeAll(closers)
+			return finished(0)
+		}
+	}
+	if f, ok := sh.funcs[args[0]]; ok {
+		return spawn(func() int { return sh.call(f, args, std) }, closers)
+	}
+	if _, ok := sh.builtins[args[0]]; ok {
+		return spawn(func() int { return sh.builtin(args, std) }, closers)
+	}
+
+	proc, err := std.job.start(sh.Exec(args, std))
+	closeAll(closers)
+	if err != nil {
+		fmt.Fprintln(std.err, err)
+		return finished(startStatus(err))
+	}
+	sh.jobs.track(std.job)
+	if sh.ctx.Err() != nil {
+		// сеанс закончился, пока процесс запускался: hangup его не видел
+		std.job.signal(syscall.SIGKILL)
+	}
+	return &stage{job: std.job, proc: proc}
+}
+
+// Pipeline - конвейер cmd1 | cmd2 | ... | cmdN. Все команды работают
+// одновременно и связаны через os.Pipe, код возврата - у последней
+func (s *Shell) Pipeline(cmds []Node, std stdio) int {
+	stages := make([]*stage, 0, len(cmds))
+	var prev io.Closer
+	for i, node := range cmds {
+		cur := std
+		var closers []io.Closer
+		if prev != nil {
+			closers = append(closers, prev)
+			prev = nil
+		}
+		if i < len(cmds)-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				fmt.Fprintln(std.err, err)
+				closeAll(closers)
+				stages = append(stages, finished(1))
+				break
+			}
+			cur.out = w
+			closers = append(closers, w)
+			std.in, prev = r, r
+		}
+		stages = append(stages, s.subshell().start(node, cur, closers...))
+	}
+
+	status := 0
+	for _, st := range stages {
+		status = st.wait()
+	}
+	return status
+}
@@ -0,0 +1,138 @@
+package wbsh
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prompt - приглашение для очередной строки: PS1 ("$ " по умолчанию)
+// или PS2 ("> ") для продолжения незаконченной команды
+func (s *Shell) prompt(continued bool) string {
+	name, def := "PS1", "$ "
+	if continued {
+		name, def = "PS2", "> "
+	}
+	ps, ok := s.Get(name)
+	if !ok {
+		return def
+	}
+	return s.expandPrompt(ps, time.Now())
+}
+
+// expandPrompt - раскрытие экранирований приглашения:
+//
+//	\u - имя пользователя, \h - имя хоста до первой точки, \H - полное
+//	\w - рабочий каталог с ~ вместо домашнего, \W - его последняя компонента
+//	\? - код возврата последней команды
+//	\t - время ЧЧ:ММ:СС, \T - то же в 12-часовом формате, \A - ЧЧ:ММ, \d - дата
+//	\g - ветка git для рабочего каталога, пусто вне репозитория
+//	\$ - # для root, иначе $
+//	\n - перевод строки, \e - ESC, \\ - обратный слэш
+//	\[ и \] - границы непечатаемых последовательностей, отбрасываются
+func (s *Shell) expandPrompt(ps string, now time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(ps); i++ {
+		if ps[i] != '\\' || i == len(ps)-1 {
+			b.WriteByte(ps[i])
+			continue
+		}
+		i++
+		switch ps[i] {
+		case 'u':
+			if u, err := user.Current(); err == nil {
+				b.WriteString(u.Username)
+			}
+		case 'h', 'H':
+			host, _ := os.Hostname()
+			if ps[i] == 'h' {
+				host, _, _ = strings.Cut(host, ".")
+			}
+			b.WriteString(host)
+		case 'w':
+			home, _ := s.Get("HOME")
+			b.WriteString(tildePath(s.dir, home))
+		case 'W':
+			home, _ := s.Get("HOME")
+			if dir := tildePath(s.dir, home); dir == "~" || dir == "/" {
+				b.WriteString(dir)
+			} else {
+				b.WriteString(filepath.Base(dir))
+			}
+		case '?':
+			b.WriteString(strconv.Itoa(s.Status))
+		case 't':
+			b.WriteString(now.Format("15:04:05"))
+		case 'T':
+			b.WriteString(now.Format("03:04:05"))
+		case 'A':
+			b.WriteString(now.Format("15:04"))
+		case 'd':
+			b.WriteString(now.Format("Mon Jan 02"))
+		case 'g':
+			b.WriteString(gitBranch(s.dir))
+		case '$':
+			if os.Geteuid() == 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('$')
+			}
+		case 'n':
+			b.WriteByte('\n')
+		case 'e':
+			b.WriteByte('\x1b')
+		case '\\':
+			b.WriteByte('\\')
+		case '[', ']':
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(ps[i])
+		}
+	}
+	return b.String()
+}
+
+// gitBranch - ветка git-репозитория, в котором лежит dir: .git ищется
+// вверх по дереву каталогов. Для отсоединённой HEAD - начало хеша
+func gitBranch(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitDir); err == nil {
+			if !info.IsDir() {
+				// рабочее дерево или подмодуль: в файле .git путь к репозиторию
+				data, err := os.ReadFile(gitDir)
+				if err != nil {
+					return ""
+				}
+				path, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+				if !ok {
+					return ""
+				}
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(dir, path)
+				}
+				gitDir = path
+			}
+			head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+			if err != nil {
+				return ""
+			}
+			ref := strings.TrimSpace(string(head))
+			if branch, ok := strings.CutPrefix(ref, "ref: refs/heads/"); ok {
+				return branch
+			}
+			return ref[:min(len(ref), 7)]
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,56 @@
+package wbsh
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandPrompt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "repo", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "repo", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "repo", ".git", "HEAD"), []byte("ref: refs/heads/feature/x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sh := NewShell(io.Discard, strings.NewReader(""))
+	sh.vars = map[string]*variable{"HOME": {value: dir}}
+	sh.dir = filepath.Join(dir, "repo", "sub")
+	sh.Status = 3
+	now := time.Date(2024, 5, 6, 14, 7, 9, 0, time.UTC)
+	sign := "$"
+	if os.Geteuid() == 0 {
+		sign = "#"
+	}
+
+	testcases := []struct {
+		ps   string
+		want string
+	}{
+		{ps: `\w \W`, want: "~/repo/sub sub"},
+		{ps: `[\?] \$ `, want: "[3] " + sign + " "},
+		{ps: `\t \T \A \d`, want: "14:07:09 02:07:09 14:07 Mon May 06"},
+		{ps: `(\g)\n> `, want: "(feature/x)\n> "},
+		{ps: `\[\e[1m\]x\\ \q`, want: "\x1b[1mx\\ \\q"},
+		{ps: `end\`, want: `end\`},
+	}
+
+	for _, tc := range testcases {
+		if got := sh.expandPrompt(tc.ps, now); got != tc.want {
+			t.Errorf("%q: got: %q, want: %q", tc.ps, got, tc.want)
+		}
+	}
+
+	sh.dir = dir
+	if got := sh.expandPrompt(`\w|\W|\g`, now); got != "~|~|" {
+		t.Errorf("home: got: %q", got)
+	}
+}
@@ -0,0 +1,625 @@
+package wbsh
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clkTck - частота тиков, в которых /proc отдаёт время процесса
+const clkTck = 100
+
+var errPsFormat = errors.New("ps: improper format list")
+
+// procInfo - сведения о процессе из /proc/<pid>
+type procInfo struct {
+	pid, ppid, pgid, sid, tpgid int
+	uid                         int
+	state                       byte
+	comm                        string
+	args                        []string
+	tty                         int // номер устройства терминала, 0 - нет
+	nice, threads               int
+	cpu                         uint64 // время в пользовательском режиме и ядре, тики
+	start                       uint64 // время запуска с загрузки системы, тики
+	vsz, rss                    uint64 // КиБ
+	depth                       int    // глубина в дереве --forest
+}
+
+// parseStat - разбор /proc/<pid>/stat. Имя команды в скобках может
+// само содержать пробелы и скобки, поэтому ищется последняя ')'
+func parseStat(data string) (*procInfo, error) {
+	open, end := strings.IndexByte(data, '('), strings.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("bad stat: %q", data)
+	}
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("bad stat: %q", data)
+	}
+	num := func(i int) int64 {
+		n, _ := strconv.ParseInt(fields[i], 10, 64)
+		return n
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(data[:open]))
+	if err != nil {
+		return nil, err
+	}
+	return &procInfo{
+		pid:     pid,
+		comm:    data[open+1 : end],
+		state:   fields[0][0],
+		ppid:    int(num(1)),
+		pgid:    int(num(2)),
+		sid:     int(num(3)),
+		tty:     int(num(4)),
+		tpgid:   int(num(5)),
+		cpu:     uint64(num(11) + num(12)),
+		nice:    int(num(16)),
+		threads: int(num(17)),
+		start:   uint64(num(19)),
+		vsz:     uint64(num(20)) / 1024,
+		rss:     uint64(num(21)) * uint64(os.Getpagesize()) / 1024,
+	}, nil
+}
+
+// readProc - сведения о процессе из каталога dir
+func readProc(dir string) (*procInfo, error) {
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	p, err := parseStat(string(stat))
+	if err != nil {
+		return nil, err
+	}
+	if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			if value, ok := strings.CutPrefix(line, "Uid:"); ok {
+				if fields := strings.Fields(value); len(fields) > 1 {
+					p.uid, _ = strconv.Atoi(fields[1])
+				}
+			}
+		}
+	}
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+		p.args = strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+	}
+	return p, nil
+}
+
+// readProcs - все процессы из каталога root (/proc). Процессы,
+// завершившиеся во время чтения, пропускаются
+func readProcs(root string) ([]*procInfo, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var procs []*procInfo
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		if p, err := readProc(filepath.Join(root, entry.Name())); err == nil {
+			procs = append(procs, p)
+		}
+	}
+	return procs, nil
+}
+
+// psEnv - общие сведения о системе для вычисления колонок
+type psEnv struct {
+	boot     time.Time // время загрузки
+	now      time.Time
+	memTotal uint64 // КиБ
+	forest   bool
+	users    map[int]string
+}
+
+// newPsEnv - чтение времени загрузки и объёма памяти из root
+func newPsEnv(root string) *psEnv {
+	env := &psEnv{now: time.Now(), users: make(map[int]string)}
+	scan := func(name string, fn func(key string, value uint64)) {
+		f, err := os.Open(filepath.Join(root, name))
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if fields := strings.Fields(sc.Text()); len(fields) > 1 {
+				value, _ := strconv.ParseUint(fields[1], 10, 64)
+				fn(fields[0], value)
+			}
+		}
+	}
+	scan("stat", func(key string, value uint64) {
+		if key == "btime" {
+			env.boot = time.Unix(int64(value), 0)
+		}
+	})
+	scan("meminfo", func(key string, value uint64) {
+		if key == "MemTotal:" {
+			env.memTotal = value
+		}
+	})
+	return env
+}
+
+// user - имя пользователя по uid
+func (env *psEnv) user(uid int) string {
+	if name, ok := env.users[uid]; ok {
+		return name
+	}
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	env.users[uid] = name
+	return name
+}
+
+// started - время запуска процесса
+func (env *psEnv) started(p *procInfo) time.Time {
+	return env.boot.Add(time.Duration(p.start) * time.Second / clkTck)
+}
+
+// elapsed - время работы процесса в секундах
+func (env *psEnv) elapsed(p *procInfo) float64 {
+	return env.now.Sub(env.started(p)).Seconds()
+}
+
+// ttyName - имя терминала по номеру устройства
+func ttyName(dev int) string {
+	major, minor := (dev>>8)&0xfff, (dev&0xff)|((dev>>12)&0xfff00)
+	switch {
+	case dev == 0:
+		return "?"
+	case major == 136:
+		return fmt.Sprintf("pts/%v", minor)
+	case major == 4 && minor < 64:
+		return fmt.Sprintf("tty%v", minor)
+	case major == 4:
+		return fmt.Sprintf("ttyS%v", minor-64)
+	}
+	return fmt.Sprintf("%v:%v", major, minor)
+}
+
+// formatDuration - время в виде [дни-]чч:мм:сс
+func formatDuration(seconds uint64) string {
+	days, hms := seconds/86400, fmt.Sprintf("%02d:%02d:%02d", seconds/3600%24, seconds/60%60, seconds%60)
+	if days > 0 {
+		return fmt.Sprintf("%v-%v", days, hms)
+	}
+	return hms
+}
+
+// psColumn - колонка вывода ps. num задаётся у числовых колонок:
+// они выравниваются вправо и сортируются как числа
+type psColumn struct {
+	header string
+	text   func(p *procInfo, env *psEnv) string
+	num    func(p *procInfo, env *psEnv) float64
+}
+
+// intColumn - числовая колонка из целого поля процесса
+func intColumn(header string, field func(p *procInfo) int) psColumn {
+	return psColumn{
+		header: header,
+		num:    func(p *procInfo, _ *psEnv) float64 { return float64(field(p)) },
+	}
+}
+
+// command - имя или командная строка процесса, в --forest с отступом
+func command(full bool) func(p *procInfo, env *psEnv) string {
+	return func(p *procInfo, env *psEnv) string {
+		cmd := p.comm
+		if full && len(p.args) > 0 {
+			cmd = strings.Join(p.args, " ")
+		} else if full || p.state == 'Z' {
+			cmd = "[" + p.comm + "]"
+		}
+		if env.forest && p.depth > 0 {
+			cmd = strings.Repeat("    ", p.depth-1) + " \\_ " + cmd
+		}
+		return cmd
+	}
+}
+
+// psColumns - колонки, которые можно указать в -o и --sort
+var psColumns = map[string]psColumn{
+	"pid":  intColumn("PID", func(p *procInfo) int { return p.pid }),
+	"ppid": intColumn("PPID", func(p *procInfo) int { return p.ppid }),
+	"pgid": intColumn("PGID", func(p *procInfo) int { return p.pgid }),
+	"sid":  intColumn("SID", func(p *procInfo) int { return p.sid }),
+	"uid":  intColumn("UID", func(p *procInfo) int { return p.uid }),
+	"ni":   intColumn("NI", func(p *procInfo) int { return p.nice }),
+	"nlwp": intColumn("NLWP", func(p *procInfo) int { return p.threads }),
+	"rss":  intColumn("RSS", func(p *procInfo) int { return int(p.rss) }),
+	"vsz":  intColumn("VSZ", func(p *procInfo) int { return int(p.vsz) }),
+	"user": {header: "USER", text: func(p *procInfo, env *psEnv) string { return env.user(p.uid) }},
+	"s":    {header: "S", text: func(p *procInfo, _ *psEnv) string { return string(p.state) }},
+	"stat": {header: "STAT", text: func(p *procInfo, _ *psEnv) string { return stat(p) }},
+	"tty":  {header: "TT", text: func(p *procInfo, _ *psEnv) string { return ttyName(p.tty) }},
+	"comm": {header: "COMMAND", text: command(false)},
+	"args": {header: "COMMAND", text: command(true)},
+	"cmd":  {header: "CMD", text: command(true)},
+	"%cpu": {
+		header: "%CPU",
+		text:   func(p *procInfo, env *psEnv) string { return fmt.Sprintf("%.1f", cpuPercent(p, env)) },
+		num:    cpuPercent,
+	},
+	"%mem": {
+		header: "%MEM",
+		text:   func(p *procInfo, env *psEnv) string { return fmt.Sprintf("%.1f", memPercent(p, env)) },
+		num:    memPercent,
+	},
+	"time": {
+		header: "TIME",
+		text:   func(p *procInfo, _ *psEnv) string { return formatDuration(p.cpu / clkTck) },
+		num:    func(p *procInfo, _ *psEnv) float64 { return float64(p.cpu) },
+	},
+	"etime": {
+		header: "ELAPSED",
+		text:   func(p *procInfo, env *psEnv) string { return formatDuration(uint64(env.elapsed(p))) },
+		num:    func(p *procInfo, env *psEnv) float64 { return env.elapsed(p) },
+	},
+	"stime": {
+		header: "STIME",
+		text: func(p *procInfo, env *psEnv) string {
+			start := env.started(p)
+			if start.Format(time.DateOnly) == env.now.Format(time.DateOnly) {
+				return start.Format("15:04")
+			}
+			return start.Format("Jan02")
+		},
+		num: func(p *procInfo, _ *psEnv) float64 { return float64(p.start) },
+	},
+}
+
+// psAliases - другие имена колонок
+var psAliases = map[string]string{
+	"tname": "tty", "tt": "tty", "state": "s", "command": "args", "ucmd": "comm",
+	"pcpu": "%cpu", "pmem": "%mem", "cputime": "time", "start": "stime",
+	"euser": "user", "uname": "user", "euid": "uid", "nice": "ni", "thcount": "nlwp",
+	"rssize": "rss", "vsize": "vsz", "pgrp": "pgid", "session": "sid",
+}
+
+// lookupColumn - колонка по имени или синониму
+func lookupColumn(name string) (psColumn, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := psAliases[name]; ok {
+		name = alias
+	}
+	col, ok := psColumns[name]
+	return col, ok
+}
+
+// stat - состояние процесса с флагами, как в колонке STAT procps
+func stat(p *procInfo) string {
+	s := string(p.state)
+	switch {
+	case p.nice < 0:
+		s += "<"
+	case p.nice > 0:
+		s += "N"
+	}
+	if p.pid == p.sid {
+		s += "s"
+	}
+	if p.threads > 1 {
+		s += "l"
+	}
+	if p.tty != 0 && p.pgid == p.tpgid {
+		s += "+"
+	}
+	return s
+}
+
+// cpuPercent - доля процессорного времени за время жизни процесса
+func cpuPercent(p *procInfo, env *psEnv) float64 {
+	if elapsed := env.elapsed(p); elapsed > 0 {
+		return float64(p.cpu) / clkTck / elapsed * 100
+	}
+	return 0
+}
+
+// memPercent - доля занятой процессом физической памяти
+func memPercent(p *procInfo, env *psEnv) float64 {
+	if env.memTotal == 0 {
+		return 0
+	}
+	return float64(p.rss) / float64(env.memTotal) * 100
+}
+
+// psField - выбранная колонка с заголовком
+type psField struct {
+	psColumn
+	header string
+}
+
+// parseFormat - разбор списка колонок -o: "pid,user=ВЛАДЕЛЕЦ,cmd".
+// Заголовок после = может содержать запятые и занимает остаток списка
+func parseFormat(format string) ([]psField, error) {
+	var fields []psField
+	for format != "" {
+		item, rest, _ := strings.Cut(format, ",")
+		name, header, custom := strings.Cut(item, "=")
+		if custom {
+			header, rest = strings.TrimPrefix(format, name+"="), ""
+		}
+		col, ok := lookupColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column '%v'", errPsFormat, name)
+		}
+		if !custom {
+			header = col.header
+		}
+		fields = append(fields, psField{psColumn: col, header: header})
+		format = rest
+	}
+	if len(fields) == 0 {
+		return nil, errPsFormat
+	}
+	return fields, nil
+}
+
+// psSortKey - ключ сортировки --sort, desc - по убыванию (-key)
+type psSortKey struct {
+	col  psColumn
+	desc bool
+}
+
+// parseSort - разбор списка ключей --sort: "-rss,pid"
+func parseSort(spec string) ([]psSortKey, error) {
+	var keys []psSortKey
+	for _, name := range strings.Split(spec, ",") {
+		key := psSortKey{}
+		switch {
+		case strings.HasPrefix(name, "-"):
+			key.desc, name = true, name[1:]
+		case strings.HasPrefix(name, "+"):
+			name = name[1:]
+		}
+		col, ok := lookupColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("ps: unknown sort key '%v'", name)
+		}
+		key.col = col
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// compare - сравнение процессов по ключам сортировки
+func compare(keys []psSortKey, env *psEnv) func(a, b *procInfo) int {
+	return func(a, b *procInfo) int {
+		for _, key := range keys {
+			var c int
+			if key.col.num != nil {
+				c = cmp.Compare(key.col.num(a, env), key.col.num(b, env))
+			} else {
+				c = strings.Compare(key.col.text(a, env), key.col.text(b, env))
+			}
+			if key.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return a.pid - b.pid
+	}
+}
+
+// forest - упорядочение процессов деревом: дети идут сразу за родителем
+func forest(procs []*procInfo, order func(a, b *procInfo) int) []*procInfo {
+	byPid := make(map[int]bool, len(procs))
+	children := make(map[int][]*procInfo)
+	for _, p := range procs {
+		byPid[p.pid] = true
+	}
+	var roots []*procInfo
+	for _, p := range procs {
+		if byPid[p.ppid] && p.ppid != p.pid {
+			children[p.ppid] = append(children[p.ppid], p)
+		} else {
+			roots = append(roots, p)
+		}
+	}
+
+	sorted := make([]*procInfo, 0, len(procs))
+	var walk func(list []*procInfo, depth int)
+	walk = func(list []*procInfo, depth int) {
+		slices.SortStableFunc(list, order)
+		for _, p := range list {
+			p.depth = depth
+			sorted = append(sorted, p)
+			walk(children[p.pid], depth+1)
+		}
+	}
+	walk(roots, 0)
+	return sorted
+}
+
+// psOptions - разобранные аргументы ps
+type psOptions struct {
+	all     bool
+	full    bool
+	formats []string // аргументы -o
+	sort    string
+	forest  bool
+}
+
+// parsePsArgs - разбор аргументов: -e (-A), -f, -o формат, --sort ключи,
+// --forest. Короткие флаги можно объединять: -ef
+func parsePsArgs(args []string) (psOptions, error) {
+	var opts psOptions
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		next := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("ps: option '%v' requires an argument", arg)
+			}
+			i++
+			return args[i], nil
+		}
+		var err error
+		switch {
+		case arg == "--forest":
+			opts.forest = true
+		case arg == "--sort":
+			opts.sort, err = next()
+		case strings.HasPrefix(arg, "--sort="):
+			opts.sort = strings.TrimPrefix(arg, "--sort=")
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+		flags:
+			for j := 1; j < len(arg); j++ {
+				switch arg[j] {
+				case 'e', 'A':
+					opts.all = true
+				case 'f':
+					opts.full = true
+				case 'o':
+					format := arg[j+1:]
+					if format == "" {
+						format, err = next()
+					}
+					opts.formats = append(opts.formats, format)
+					break flags
+				default:
+					return opts, fmt.Errorf("ps: unknown option '%v'", arg)
+				}
+			}
+		default:
+			return opts, fmt.Errorf("ps: unknown option '%v'", arg)
+		}
+		if err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// ps - вывод списка процессов по данным /proc. Без -e выводятся
+// процессы текущего пользователя на том же терминале, что и шелл
+func (s *Shell) ps(args []string, out io.Writer) error {
+	return psList("/proc", args, out)
+}
+
+// psList - ps по файловой системе процессов root
+func psList(root string, args []string, out io.Writer) error {
+	opts, err := parsePsArgs(args)
+	if err != nil {
+		return err
+	}
+	formats := opts.formats
+	switch {
+	case len(formats) > 0:
+	case opts.full:
+		formats = []string{"user=UID", "pid,ppid,stime", "tname=TTY", "time", "args=CMD"}
+	default:
+		formats = []string{"pid", "tname=TTY", "time", "comm=CMD"}
+	}
+	var fields []psField
+	for _, format := range formats {
+		f, err := parseFormat(format)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, f...)
+	}
+	var keys []psSortKey
+	if opts.sort != "" {
+		if keys, err = parseSort(opts.sort); err != nil {
+			return err
+		}
+	}
+
+	procs, err := readProcs(root)
+	if err != nil {
+		return err
+	}
+	if !opts.all {
+		self, err := readProc(filepath.Join(root, strconv.Itoa(os.Getpid())))
+		if err != nil {
+			return err
+		}
+		procs = slices.DeleteFunc(procs, func(p *procInfo) bool {
+			return p.uid != self.uid || p.tty != self.tty
+		})
+	}
+
+	env := newPsEnv(root)
+	env.forest = opts.forest
+	order := compare(keys, env)
+	if opts.forest {
+		procs = forest(procs, order)
+	} else {
+		slices.SortStableFunc(procs, order)
+	}
+	return printPs(out, fields, procs, env)
+}
+
+// printPs - вывод таблицы: колонки выровнены по самому широкому
+// значению, числовые - вправо, последняя не дополняется пробелами
+func printPs(out io.Writer, fields []psField, procs []*procInfo, env *psEnv) error {
+	rows := make([][]string, 0, len(procs)+1)
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.header
+	}
+	rows = append(rows, header)
+	for _, p := range procs {
+		row := make([]string, len(fields))
+		for i, f := range fields {
+			if f.text != nil {
+				row[i] = f.text(p, env)
+			} else {
+				row[i] = strconv.FormatFloat(f.num(p, env), 'f', -1, 64)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(fields))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+	hasHeader := slices.ContainsFunc(header, func(h string) bool { return h != "" })
+	for n, row := range rows {
+		if n == 0 && !hasHeader {
+			continue
+		}
+		var b strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			switch {
+			case fields[i].num != nil:
+				fmt.Fprintf(&b, "%*v", widths[i], cell)
+			case i < len(row)-1:
+				fmt.Fprintf(&b, "%-*v", widths[i], cell)
+			default:
+				b.WriteString(cell)
+			}
+		}
+		if _, err := fmt.Fprintln(out, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
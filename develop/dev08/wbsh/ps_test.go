@@ -0,0 +1,87 @@
+package wbsh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseStat(t *testing.T) {
+	p, err := parseStat("42 (a) (b) S 1 42 42 34816 42 4194560 0 0 0 0 150 50 0 0 20 0 3 0 1000 8192000 25 18446744073709551615")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.pid != 42 || p.comm != "a) (b" || p.state != 'S' || p.ppid != 1 || p.cpu != 200 || p.threads != 3 || p.vsz != 8000 {
+		t.Errorf("got: %+v", p)
+	}
+	if got := ttyName(p.tty); got != "pts/0" {
+		t.Errorf("tty: %v, want: pts/0", got)
+	}
+	if got := stat(p); got != "Ssl+" {
+		t.Errorf("stat: %v, want: Ssl+", got)
+	}
+}
+
+func TestPs(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, data string) {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proc := func(pid, ppid, rss, cmdline string) {
+		write(pid+"/stat", pid+" (x) S "+ppid+" 1 1 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 0 0 "+rss)
+		write(pid+"/status", "Name:\tx\nUid:\t0\t0\t0\t0\n")
+		write(pid+"/cmdline", strings.ReplaceAll(cmdline, " ", "\x00")+"\x00")
+	}
+	write("stat", "cpu 0 0 0\nbtime 0\n")
+	proc("1", "0", "1", "init")
+	proc("7", "1", "3", "sh -c x")
+	proc("9", "7", "2", "sleep 10")
+	proc("5", "1", "4", "daemon")
+
+	testcases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "Format and sorting",
+			args: []string{"-e", "-o", "pid,ppid", "-o", "args=COMMAND LINE", "--sort=-ppid,pid"},
+			want: "PID PPID COMMAND LINE\n  9    7 sleep 10\n  5    1 daemon\n  7    1 sh -c x\n  1    0 init\n",
+		},
+		{
+			name: "Forest",
+			args: []string{"-A", "--forest", "-opid,cmd"},
+			want: "PID CMD\n  1 init\n  5  \\_ daemon\n  7  \\_ sh -c x\n  9      \\_ sleep 10\n",
+		},
+		{
+			name: "No headers",
+			args: []string{"-e", "-o", "pid=", "-o", "comm=", "--sort", "pid"},
+			want: "1 x\n5 x\n7 x\n9 x\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := psList(root, tc.args, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.want {
+				t.Errorf("got:\n%v\nwant:\n%v", out.String(), tc.want)
+			}
+		})
+	}
+
+	for _, args := range [][]string{{"-x"}, {"-o", "foo"}, {"--sort=bar"}, {"-o"}} {
+		if err := psList(root, args, &strings.Builder{}); err == nil {
+			t.Errorf("%q: error expected", args)
+		}
+	}
+}
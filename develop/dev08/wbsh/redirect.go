@@ -0,0 +1,128 @@
+package wbsh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// setFd - замена потока с номером fd
+func (std *stdio) setFd(fd int, f *os.File) error {
+	switch fd {
+	case 0:
+		std.in = f
+	case 1:
+		std.out = f
+	case 2:
+		std.err = f
+	default:
+		return fmt.Errorf("%v: bad file descriptor", fd)
+	}
+	return nil
+}
+
+// dupFd - перенаправление fd в уже открытый поток target (2>&1)
+func (std *stdio) dupFd(fd int, target string) error {
+	var w io.Writer
+	switch target {
+	case "1":
+		w = std.out
+	case "2":
+		w = std.err
+	default:
+		return fmt.Errorf("%v: bad file descriptor", target)
+	}
+	switch fd {
+	case 1:
+		std.out = w
+	case 2:
+		std.err = w
+	default:
+		return fmt.Errorf("%v: bad file descriptor", fd)
+	}
+	return nil
+}
+
+// hereDoc - поток ввода с текстом here-документа или here-строки:
+// канал, в который текст пишет отдельная горутина. Если команда не
+// дочитает его, запись прервётся с EPIPE после закрытия канала
+func hereDoc(text string) (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		io.WriteString(w, text)
+		w.Close()
+	}()
+	return r, nil
+}
+
+// redirect - применение перенаправлений команды к её потокам слева
+// направо. Возвращает новые потоки и открытые файлы, которые нужно
+// закрыть после запуска команды
+func (s *Shell) redirect(redirs []*Redirect, std stdio) (stdio, []io.Closer, error) {
+	var files []io.Closer
+	fail := func(err error) (stdio, []io.Closer, error) {
+		closeAll(files)
+		return std, nil, err
+	}
+
+	for _, r := range redirs {
+		if r.Op == tokDLess || r.Op == tokDLessDash || r.Op == tokTLess {
+			text := s.expandString(r.Body)
+			if r.Op == tokTLess {
+				text = s.expandString(s.expandTilde(r.Target)) + "\n"
+			}
+			f, err := hereDoc(text)
+			if err != nil {
+				return fail(err)
+			}
+			files = append(files, f)
+			if err := std.setFd(r.Fd, f); err != nil {
+				return fail(err)
+			}
+			continue
+		}
+		fields := s.expandWord(r.Target)
+		if len(fields) != 1 {
+			return fail(fmt.Errorf("%v: ambiguous redirect", r.Target))
+		}
+		target := fields[0]
+		if r.Op == tokGreatAnd {
+			if _, err := strconv.Atoi(target); err == nil {
+				if err := std.dupFd(r.Fd, target); err != nil {
+					return fail(err)
+				}
+				continue
+			}
+			if r.Fd != 1 {
+				return fail(fmt.Errorf("%v: ambiguous redirect", target))
+			}
+		}
+
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		switch r.Op {
+		case tokLess:
+			flag = os.O_RDONLY
+		case tokDGreat:
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := s.open(target, flag)
+		if err != nil {
+			return fail(err)
+		}
+		files = append(files, f)
+
+		// &> и >&файл перенаправляют сразу stdout и stderr
+		if r.Op == tokAndGreat || r.Op == tokGreatAnd {
+			std.out, std.err = f, f
+			continue
+		}
+		if err := std.setFd(r.Fd, f); err != nil {
+			return fail(err)
+		}
+	}
+	return std, files, nil
+}
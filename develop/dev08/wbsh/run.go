@@ -0,0 +1,197 @@
+package wbsh
+
+import (
+	"fmt"
+	"maps"
+)
+
+// run - выполнение узла синтаксического дерева, возвращает код возврата
+func (s *Shell) run(node Node, std stdio) int {
+	status := 0
+	switch n := node.(type) {
+	case *List:
+		for _, stmt := range n.Stmts {
+			if s.interrupted() {
+				break
+			}
+			if stmt.Background {
+				s.background(stmt, std)
+				status = 0
+				continue
+			}
+			status = s.foreground(stmt, std)
+		}
+	case *AndOr:
+		// set -e не срабатывает на командах перед && и ||
+		s.noErrexit++
+		status = s.run(n.X, std)
+		s.noErrexit--
+		if !s.exited && (n.Op == tokAnd) == (status == 0) {
+			status = s.run(n.Y, std)
+		}
+	case *Pipeline:
+		status = s.Pipeline(n.Cmds, std)
+	case *Command:
+		status = s.start(n, std).wait()
+	case *Not:
+		s.noErrexit++
+		if s.run(n.X, std) == 0 {
+			status = 1
+		}
+		s.noErrexit--
+	case *IfClause:
+		status = s.runIf(n, std)
+	case *WhileClause:
+		status = s.runWhile(n, std)
+	case *ForClause:
+		status = s.runFor(n, std)
+	case *CaseClause:
+		status = s.runCase(n, std)
+	case *Block:
+		status = s.run(n.Body, std)
+	case *Subshell:
+		status = s.subshell().run(n.Body, std)
+	case *Redirected:
+		rstd, files, err := s.redirect(n.Redirs, std)
+		if err != nil {
+			fmt.Fprintln(std.err, err)
+			status = 1
+			break
+		}
+		status = s.run(n.Node, rstd)
+		closeAll(files)
+	case *FuncDecl:
+		s.funcs[n.Name] = n
+	}
+	s.Status = status
+	if s.jobControl && std.job != nil && std.job.Interrupted() {
+		s.intr.Store(true)
+	}
+	if status != 0 && s.errexit && s.noErrexit == 0 {
+		switch node.(type) {
+		case *Command, *Pipeline, *Subshell:
+			s.exited = true
+		}
+	}
+	return status
+}
+
+// interrupted - выполнение списка команд прерывается: exit, return,
+// break, continue, Ctrl-C или конец сеанса
+func (s *Shell) interrupted() bool {
+	return s.exited || s.returning || s.breaking > 0 || s.continuing > 0 || s.intr.Load() || s.ctx.Err() != nil
+}
+
+// cond - выполнение условия, в котором set -e не действует
+func (s *Shell) cond(list *List, std stdio) bool {
+	s.noErrexit++
+	defer func() { s.noErrexit-- }()
+	return s.run(list, std) == 0
+}
+
+// runIf - if/elif/else
+func (s *Shell) runIf(n *IfClause, std stdio) int {
+	if s.cond(n.Cond, std) {
+		return s.run(n.Then, std)
+	}
+	if s.interrupted() || n.Else == nil {
+		return 0
+	}
+	return s.run(n.Else, std)
+}
+
+// loopDone - обработка break и continue в конце итерации цикла,
+// true - цикл завершается
+func (s *Shell) loopDone() bool {
+	switch {
+	case s.breaking > 0:
+		s.breaking--
+		return true
+	case s.continuing > 0:
+		// continue N > 1 продолжает внешний цикл
+		s.continuing--
+		return s.continuing > 0
+	}
+	return s.interrupted()
+}
+
+// runWhile - цикл while/until
+func (s *Shell) runWhile(n *WhileClause, std stdio) int {
+	s.loops++
+	defer func() { s.loops-- }()
+	status := 0
+	for {
+		ok := s.cond(n.Cond, std)
+		if s.interrupted() {
+			if s.loopDone() {
+				break
+			}
+			continue
+		}
+		if ok == n.Until {
+			break
+		}
+		status = s.run(n.Body, std)
+		if s.loopDone() {
+			break
+		}
+	}
+	return status
+}
+
+// runFor - цикл for по словам или позиционным параметрам
+func (s *Shell) runFor(n *ForClause, std stdio) int {
+	values := s.args
+	if n.In {
+		values = s.expand(n.Words)
+	}
+	s.loops++
+	defer func() { s.loops-- }()
+	status := 0
+	for _, value := range values {
+		s.Set(n.Name, value)
+		status = s.run(n.Body, std)
+		if s.loopDone() {
+			break
+		}
+	}
+	return status
+}
+
+// runCase - case: выполняется первая ветка, шаблон которой подошёл
+func (s *Shell) runCase(n *CaseClause, std stdio) int {
+	word := s.expandString(n.Word)
+	for _, item := range n.Items {
+		for _, pattern := range item.Patterns {
+			if matchPattern(s.expandPattern(pattern), word) {
+				return s.run(item.Body, std)
+			}
+		}
+	}
+	return 0
+}
+
+// expand - раскрытие слов команды в аргументы
+func (s *Shell) expand(words []Word) []string {
+	args := make([]string, 0, len(words))
+	for _, w := range words {
+		args = append(args, s.expandWord(w)...)
+	}
+	return args
+}
+
+// subshell - копия шелла для команды, выполняемой параллельно с ним
+// (фоновое задание, звено конвейера) или в ( ... ) и $(...): её
+// изменения переменных и каталога не видны родителю
+func (s *Shell) subshell() *Shell {
+	sub := *s
+	sub.vars = cloneVars(s.vars)
+	sub.funcs = maps.Clone(s.funcs)
+	sub.aliases = maps.Clone(s.aliases)
+	sub.frames = make([]map[string]*variable, len(s.frames))
+	for i, frame := range s.frames {
+		sub.frames[i] = cloneVars(frame)
+	}
+	sub.inSubshell = true
+	return &sub
+}
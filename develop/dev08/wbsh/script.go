@@ -0,0 +1,186 @@
+package wbsh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// execute - чтение и выполнение команд из src. Незаконченная команда
+// (открытая кавычка, '|' в конце строки, обратный слэш) дочитывается
+// со следующих строк. В интерактивном режиме выводится приглашение,
+// а синтаксическая ошибка не прерывает чтение
+func (s *Shell) execute(src lineReader, interactive bool) error {
+	var buf strings.Builder
+	line := 0
+	for !s.exited && !s.returning {
+		prompt := ""
+		if interactive {
+			prompt = s.prompt(buf.Len() > 0)
+		}
+		text, err := src.readLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			buf.Reset()
+			s.Status = 130
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		line++
+		if interactive && buf.Len() == 0 && text == `\quit` {
+			break
+		}
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+		list, err := parse(buf.String(), s.aliases)
+		if errors.Is(err, errIncomplete) {
+			continue
+		}
+		buf.Reset()
+
+		switch {
+		case err == nil:
+			s.intr.Store(false)
+			s.run(list, s.stdio())
+			if s.intr.Load() {
+				s.Status = 130
+			}
+		case interactive:
+			fmt.Fprintln(s.Err, err)
+			s.Status = 2
+		default:
+			fmt.Fprintf(s.Err, "%v: line %v: %v\n", s.name, line, err)
+			s.Status = 2
+			return nil
+		}
+		if interactive {
+			s.jobs.notify(s.Err)
+		}
+	}
+	if buf.Len() > 0 && !interactive {
+		fmt.Fprintf(s.Err, "%v: line %v: syntax error: unexpected end of file\n", s.name, line)
+		s.Status = 2
+	}
+	return nil
+}
+
+// rcFiles - файлы настроек интерактивного шелла в порядке загрузки,
+// ~/ - домашний каталог
+var rcFiles = []string{"/etc/wbshrc", "~/.wbshrc"}
+
+// loadRC - выполнение файлов настроек в текущем шелле, как source:
+// там задаются алиасы, переменные, функции и приглашение. Отсутствующие
+// файлы пропускаются, ошибки выводятся, но запуск не прерывают
+func (s *Shell) loadRC() {
+	home, _ := s.Get("HOME")
+	for _, path := range rcFiles {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home == "" {
+				continue
+			}
+			path = filepath.Join(home, rest)
+		}
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		s.builtin([]string{"source", path}, s.stdio())
+		if s.exited {
+			return
+		}
+	}
+}
+
+// Script - выполнение скрипта или строки -c, возвращает код выхода
+func (s *Shell) Script(r io.Reader) int {
+	if err := s.execute(&scanner{Scanner: bufio.NewScanner(r)}, false); err != nil {
+		fmt.Fprintf(s.Err, "%v: %v\n", s.name, err)
+		return 2
+	}
+	return s.Status
+}
+
+// set - встроенная команда set: опции -e, -x (+e, +x выключают),
+// затем позиционные параметры; без аргументов - список переменных
+func (s *Shell) set(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		for _, name := range s.names() {
+			if _, err := fmt.Fprintf(out, "%v=%v\n", name, quote(s.vars[name].value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i, arg := range args {
+		if arg == "--" {
+			s.args = args[i+1:]
+			return nil
+		}
+		if len(arg) < 2 || arg[0] != '-' && arg[0] != '+' {
+			s.args = args[i:]
+			return nil
+		}
+		for _, opt := range arg[1:] {
+			switch opt {
+			case 'e':
+				s.errexit = arg[0] == '-'
+			case 'x':
+				s.xtrace = arg[0] == '-'
+			default:
+				return fmt.Errorf("set: %v: invalid option", arg)
+			}
+		}
+	}
+	return nil
+}
+
+// shift - встроенная команда shift [n]: сдвиг позиционных параметров,
+// $n+1 становится $1
+func (s *Shell) shift(args []string) error {
+	n := 1
+	switch len(args) {
+	case 0:
+	case 1:
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			return fmt.Errorf("shift: %v: numeric argument required", args[0])
+		}
+	default:
+		return errors.New("shift: too many arguments")
+	}
+	if n > len(s.args) {
+		return fmt.Errorf("shift: %v: shift count out of range", n)
+	}
+	s.args = s.args[n:]
+	return nil
+}
+
+// quote - аргумент в виде, пригодном для повторного ввода в шелл
+func quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$|&;<>()*?[]#~`") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// trace - вывод выполняемой команды для set -x
+func (s *Shell) trace(w io.Writer, assigns, args []string) {
+	words := make([]string, 0, len(assigns)+len(args))
+	for _, a := range assigns {
+		name, value, _ := strings.Cut(a, "=")
+		words = append(words, name+"="+quote(value))
+	}
+	for _, arg := range args {
+		words = append(words, quote(arg))
+	}
+	fmt.Fprintln(w, "+ "+strings.Join(words, " "))
+}
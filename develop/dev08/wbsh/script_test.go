@@ -0,0 +1,110 @@
+package wbsh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScriptStatus(t *testing.T) {
+	testcases := []struct {
+		name   string
+		input  string
+		status int
+		out    string
+	}{
+		{name: "Last command", input: "false; true", status: 0},
+		{name: "Failed last command", input: "true; false", status: 1},
+		{name: "Exit status variable", input: "false\necho $?; echo $?", out: "1\n0\n"},
+		{name: "And stops on failure", input: "false && echo no", status: 1},
+		{name: "Or runs on failure", input: "false || echo yes", out: "yes\n"},
+		{name: "Chain", input: "true && false || echo $?", out: "1\n"},
+		{name: "Negation", input: "! true", status: 1},
+		{name: "External command", input: "sh -c 'exit 5'; echo $?", out: "5\n"},
+		{name: "Command not found", input: "no-such-command-wbsh 2>/dev/null", status: 127},
+		{name: "Builtin error", input: "cd /no/such/dir 2>/dev/null", status: 1},
+		{name: "Exit", input: "exit 3; echo no", status: 3},
+		{name: "Exit in subshell", input: "(exit 4); echo $?", out: "4\n"},
+		{name: "Shift", input: "set -- a b c d\nshift; echo $# $1\nshift 2; echo $@\nshift 2 2>/dev/null; echo $? $#", out: "3 b\nd\n1 1\n"},
+		{name: "Shift in function", input: "f() { shift; echo $@; }; set -- x y; f 1 2 3; echo $@", out: "2 3\nx y\n"},
+		{name: "Export listing quotes values", input: "export WBSH_A='a$b c'\nexport | grep '^export WBSH_A='", out: "export WBSH_A='a$b c'\n"},
+		{name: "Failed exec exits", input: "exec no-such-command-wbsh 2>/dev/null; echo after", status: 127},
+		{name: "Background builtins", input: "(exit 3) &\nwait $!; echo $? $!", out: "[1]\n3 %1\n"},
+		{name: "Bare echo under set -e", input: "set -e\necho\necho -n -n a; echo b", out: "\nab\n"},
+		{name: "Colon builtins", input: "while :; do echo loop; break; done; : ignored; echo $?\ntrue; false || echo $?", out: "loop\n0\n1\n"},
+		{name: "Syntax error", input: "echo a\nfi\necho b", status: 2, out: "a\nwbsh: line 2: syntax error near unexpected token 'fi'\n"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			sh := NewShell(&out, strings.NewReader(""))
+			if status := sh.Script(strings.NewReader(tc.input)); status != tc.status {
+				t.Errorf("status: %v, want: %v", status, tc.status)
+			}
+			if out.String() != tc.out {
+				t.Errorf("out: %q, want: %q", out.String(), tc.out)
+			}
+		})
+	}
+}
+
+func TestLoadRC(t *testing.T) {
+	dir := t.TempDir()
+	global := filepath.Join(dir, "wbshrc")
+	if err := os.WriteFile(global, []byte("PS1='\\W> '\nX=global\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rc := "alias ll='echo ll'\nX=$X-user\ngreet() { echo hi $1; }\n"
+	if err := os.WriteFile(filepath.Join(dir, ".wbshrc"), []byte(rc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saved := rcFiles
+	rcFiles = []string{global, filepath.Join(dir, "missing"), "~/.wbshrc"}
+	defer func() { rcFiles = saved }()
+
+	var out strings.Builder
+	sh := NewShell(&out, strings.NewReader(""))
+	sh.vars = map[string]*variable{"HOME": {value: dir}}
+	sh.loadRC()
+	sh.Script(strings.NewReader("ll\ngreet you\necho $X"))
+
+	if want := "ll\nhi you\nglobal-user\n"; out.String() != want {
+		t.Errorf("out: %q, want: %q", out.String(), want)
+	}
+	if ps, _ := sh.Get("PS1"); ps != `\W> ` {
+		t.Errorf("PS1: %q", ps)
+	}
+}
+
+func TestMainPipedStdin(t *testing.T) {
+	dir := t.TempDir()
+	rc := filepath.Join(dir, "wbshrc")
+	if err := os.WriteFile(rc, []byte("echo from rc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saved := rcFiles
+	rcFiles = []string{rc}
+	defer func() { rcFiles = saved }()
+
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	stdout, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	savedIn, savedOut := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, stdout
+	defer func() { os.Stdin, os.Stdout = savedIn, savedOut }()
+
+	status := Main(nil)
+	out, _ := os.ReadFile(stdout.Name())
+	if status != 0 || strings.Contains(string(out), "from rc") {
+		t.Errorf("status: %v, out: %q", status, out)
+	}
+}
@@ -0,0 +1,161 @@
+package wbsh
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerConfig - параметры режима сервера
+type ServerConfig struct {
+	Token string        // пустой - без аутентификации; по tcp идёт открытым текстом
+	Idle  time.Duration // 0 - без ограничения простоя
+	RC    bool          // загружать файлы настроек в каждом сеансе
+	Log   io.Writer
+	// Setup - настройка шелла нового сеанса до файлов настроек,
+	// например регистрация своих встроенных команд
+	Setup func(*Shell)
+}
+
+// Listen - открытие адреса вида unix:/path/to.sock, tcp:host:port или
+// host:port. Сокет Unix доступен только владельцу; оставшийся от
+// прежнего запуска файл сокета удаляется, если его никто не слушает
+func Listen(addr string) (net.Listener, error) {
+	network, address, ok := strings.Cut(addr, ":")
+	if !ok || network != "unix" && network != "tcp" {
+		network, address = "tcp", addr
+	}
+	if network == "tcp" {
+		return net.Listen(network, address)
+	}
+
+	if info, err := os.Lstat(address); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", address); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%v: address already in use", address)
+		}
+		os.Remove(address)
+	}
+	ln, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(address, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Serve - приём соединений, у каждого свой независимый шелл. С отменой
+// ctx приём прекращается, открытые сеансы закрываются вместе с их процессами
+func Serve(ctx context.Context, ln net.Listener, cfg ServerConfig) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session(ctx, conn, cfg)
+		}()
+	}
+}
+
+// idleConn - соединение, чтение из которого прерывается, если клиент
+// молчит дольше idle
+type idleConn struct {
+	net.Conn
+	idle time.Duration
+}
+
+func (c *idleConn) Read(p []byte) (int, error) {
+	if c.idle > 0 {
+		c.SetReadDeadline(time.Now().Add(c.idle))
+	}
+	return c.Conn.Read(p)
+}
+
+// hangupDelay - сколько процессы сеанса могут завершаться после SIGHUP
+const hangupDelay = time.Second
+
+// session - сеанс шелла на соединении: проверка токена (первая строка
+// клиента), затем интерактивный шелл до exit, \quit, конца ввода или
+// истечения времени простоя. Внешние команды получают пустой ввод.
+// С концом сеанса или остановкой сервера все запущенные в нём процессы
+// завершаются
+func session(ctx context.Context, conn net.Conn, cfg ServerConfig) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	peer := conn.RemoteAddr().String()
+	if peer == "" || peer == "@" {
+		peer = "unix socket"
+	}
+	r := bufio.NewReader(&idleConn{Conn: conn, idle: cfg.Idle})
+	if cfg.Token != "" {
+		fmt.Fprint(conn, "token: ")
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(strings.TrimRight(line, "\r\n")), []byte(cfg.Token)) != 1 {
+			fmt.Fprintln(conn, "authentication failed")
+			fmt.Fprintf(cfg.Log, "wbsh: %v: authentication failed\n", peer)
+			return
+		}
+	}
+	fmt.Fprintf(cfg.Log, "wbsh: %v: session started\n", peer)
+
+	sh := NewShell(conn, r)
+	sh.ctx = ctx
+	// токен сервера не должен попасть в сеанс через окружение
+	delete(sh.vars, "WBSH_TOKEN")
+	// exec не должен заменять процесс сервера
+	sh.inSubshell = true
+	// без терминала задания всё равно в своих группах: так hangup
+	// достаёт и процессы, запущенные командами
+	sh.groupJobs = true
+	hungUp := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		sh.hangup(hangupDelay)
+		close(hungUp)
+	}()
+	if cfg.Setup != nil {
+		cfg.Setup(sh)
+	}
+	if cfg.RC {
+		sh.loadRC()
+	}
+	err := sh.Run()
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		fmt.Fprintln(conn, "\nidle timeout")
+	}
+	cancel()
+	<-hungUp
+	fmt.Fprintf(cfg.Log, "wbsh: %v: session ended, status %v\n", peer, sh.Status)
+}
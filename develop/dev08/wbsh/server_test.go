@@ -0,0 +1,126 @@
+package wbsh
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	ln, err := Listen("unix:" + filepath.Join(t.TempDir(), "wbsh.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log syncBuilder
+	t.Setenv("WBSH_TOKEN", "secret")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Serve(ctx, ln, ServerConfig{Token: "secret", Idle: time.Second, Log: &log, Setup: func(sh *Shell) {
+			sh.Register(NewBuiltin("hello", "hello", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+				io.WriteString(stdout, "hello from setup\n")
+				return 0
+			}))
+		}})
+	}()
+
+	testcases := []struct {
+		name  string
+		input string
+		out   string
+	}{
+		{name: "Session", input: "secret\necho hi\nX=1; echo $X\nhello\nexit 3\n", out: "token: $ hi\n$ 1\n$ hello from setup\n$ "},
+		{name: "Token not in environment", input: "secret\necho ${WBSH_TOKEN:-none}\nenv | grep -c WBSH_TOKEN\nexit\n", out: "token: $ none\n$ 0\n$ "},
+		{name: "Wrong token", input: "guess\necho hi\n", out: "token: authentication failed\n"},
+		{name: "Idle timeout", input: "secret\n", out: "token: $ \nidle timeout\n"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := net.Dial("unix", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			io.WriteString(conn, tc.input)
+			out, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tc.out {
+				t.Errorf("out: %q, want: %q", out, tc.out)
+			}
+		})
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), "session ended, status 3") {
+		t.Errorf("log: %q", log.String())
+	}
+}
+
+func TestServeHangup(t *testing.T) {
+	ln, err := Listen("unix:" + filepath.Join(t.TempDir(), "wbsh.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- Serve(ctx, ln, ServerConfig{Log: io.Discard}) }()
+
+	// фоновое задание отключившегося клиента
+	conn, err := net.Dial("unix", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(conn, "sleep 20 &\n")
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(line)
+	pid, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		t.Fatalf("line: %q", line)
+	}
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("background job survived the session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// остановка сервера во время команды переднего плана
+	conn, err = net.Dial("unix", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "sleep 20; echo after\n")
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve waits for the foreground command")
+	}
+	if out, _ := io.ReadAll(conn); strings.Contains(string(out), "after") {
+		t.Errorf("out: %q", out)
+	}
+}
@@ -0,0 +1,329 @@
+// Package wbsh - командный шелл wbsh: разбор и выполнение команд,
+// задания, встроенные команды и режим сервера. Программа может
+// встроить шелл и добавить свои команды через Shell.Register
+package wbsh
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	errCd   = errors.New("cd: too many arguments")
+	errPwd  = errors.New("pwd must not have any arguments")
+	errKill = errors.New("kill must have 1+ argument")
+
+	errNoHome   = errors.New("cd: HOME not set")
+	errNoOldpwd = errors.New("cd: OLDPWD not set")
+)
+
+// Main - запуск шелла из командной строки: -c 'команды' [$0 $1...],
+// скрипт с аргументами, режим сервера или интерактивный сеанс.
+// Возвращает код выхода процесса
+func Main(args []string) int {
+	fl := flag.NewFlagSet("wbsh", flag.ContinueOnError)
+	command := fl.String("c", "", "execute commands from the string")
+	errexit := fl.Bool("e", false, "exit immediately if a command fails")
+	xtrace := fl.Bool("x", false, "print commands before execution")
+	norc := fl.Bool("norc", false, "do not read /etc/wbshrc and ~/.wbshrc in an interactive shell")
+	addr := fl.String("listen", "", "serve shell sessions on `addr`: unix:/path, tcp:host:port or host:port")
+	tokenFile := fl.String("token-file", "", "require the token from `file` (default $WBSH_TOKEN) from clients; over tcp it is sent in plain text")
+	idle := fl.Duration("idle-timeout", 0, "close remote sessions idle for `duration` (0 - never)")
+	if err := fl.Parse(args); err != nil {
+		return 2
+	}
+	if *addr != "" {
+		return server(*addr, *tokenFile, *idle, !*norc)
+	}
+
+	sh := NewShell(os.Stdout, os.Stdin)
+	sh.Err = os.Stderr
+	sh.errexit, sh.xtrace = *errexit, *xtrace
+
+	isCommand := false
+	fl.Visit(func(f *flag.Flag) { isCommand = isCommand || f.Name == "c" })
+	switch {
+	case isCommand:
+		if fl.NArg() > 0 {
+			sh.name, sh.args = fl.Arg(0), fl.Args()[1:]
+		}
+		return sh.Script(strings.NewReader(*command))
+	case fl.NArg() > 0:
+		f, err := os.Open(fl.Arg(0))
+		if err != nil {
+			fmt.Fprintf(sh.Err, "%v: %v\n", sh.name, err)
+			return 127
+		}
+		defer f.Close()
+		sh.name, sh.args = fl.Arg(0), fl.Args()[1:]
+		return sh.Script(f)
+	}
+
+	sh.handleSignals()
+	// файлы настроек - только для интерактивного шелла, не для
+	// команд из канала: printf 'echo x' | wbsh
+	tty := isTerminal(int(os.Stdin.Fd()))
+	if tty {
+		if err := sh.initJobControl(int(os.Stdin.Fd())); err != nil {
+			fmt.Fprintf(sh.Err, "%v: no job control: %v\n", sh.name, err)
+		}
+	}
+	if tty && !*norc {
+		sh.loadRC()
+	}
+	if err := sh.Run(); err != nil {
+		fmt.Fprintf(sh.Err, "%v: %v\n", sh.name, err)
+		return 1
+	}
+	// код выхода - у exit N или последней команды
+	return sh.Status
+}
+
+// server - режим сервера: шелл для каждого клиента на addr до SIGINT
+// или SIGTERM. Токен из $WBSH_TOKEN убирается из окружения, чтобы его
+// не видели сеансы и их процессы. По tcp токен передаётся открытым
+// текстом: вне localhost нужен туннель (ssh, TLS-прокси)
+func server(addr, tokenFile string, idle time.Duration, rc bool) int {
+	cfg := ServerConfig{Token: os.Getenv("WBSH_TOKEN"), Idle: idle, RC: rc, Log: os.Stderr}
+	os.Unsetenv("WBSH_TOKEN")
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "wbsh: %v\n", err)
+			return 1
+		}
+		cfg.Token = strings.TrimSpace(string(data))
+	}
+	if cfg.Token == "" {
+		fmt.Fprintln(os.Stderr, "wbsh: warning: no token set, sessions are not authenticated")
+	}
+
+	ln, err := Listen(addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wbsh: %v\n", err)
+		return 1
+	}
+	defer ln.Close()
+	fmt.Fprintf(os.Stderr, "wbsh: listening on %v\n", ln.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := Serve(ctx, ln, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "wbsh: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// Shell - основная структура программы с конфигов
+type Shell struct {
+	Out     io.Writer
+	Err     io.Writer
+	In      io.Reader
+	Status  int
+	jobs    *jobTable
+	vars    map[string]*variable
+	lastBg  string // $!: pid последнего фонового задания или %n, если процессов в нём нет
+	lastJob *Job   // это задание: wait $! работает и после его удаления из таблицы
+
+	name string   // $0
+	args []string // позиционные параметры $1...$N
+
+	errexit     bool // set -e
+	xtrace      bool // set -x
+	exited      bool // шелл завершается, дальнейшие команды не выполняются
+	noErrexit   int  // глубина условий, где set -e не действует
+	inSubshell  bool
+	interactive bool     // команды читаются из Run, а не из скрипта или -c
+	dir         string   // рабочий каталог: у подоболочки свой, процесс не меняет его
+	dirStack    []string // pushd/popd: каталоги под текущим, верхний первый
+	substs      int      // число выполненных подстановок команд
+	sourcing    int      // глубина вложенности source: return завершает файл
+	aliases     map[string]string
+	builtins    map[string]Builtin
+	ctx         context.Context // контекст встроенных команд
+
+	funcs      map[string]*FuncDecl
+	frames     []map[string]*variable // переменные, скрытые local, по вызовам функций
+	loops      int                    // глубина вложенности циклов
+	breaking   int                    // break N: сколько циклов осталось прервать
+	continuing int                    // continue N
+	returning  bool                   // return: выход из функции
+
+	intr       *atomic.Bool // Ctrl-C: выполнение команды прерывается
+	jobControl bool         // задания переднего плана получают терминал
+	groupJobs  bool         // у всех заданий своя группа процессов, даже без терминала
+	tty        int
+	pgid       int // группа процессов шелла
+	termios    *syscall.Termios
+}
+
+// stdio - потоки ввода-вывода отдельной команды и задание,
+// к которому относятся её процессы
+type stdio struct {
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+	job *Job
+}
+
+// NewShell - инициализация Shell
+func NewShell(w io.Writer, r io.Reader) *Shell {
+	dir, _ := os.Getwd()
+	s := &Shell{
+		Out: w, Err: w, In: r, jobs: &jobTable{}, vars: environ(), funcs: make(map[string]*FuncDecl),
+		aliases: make(map[string]string), name: "wbsh", intr: &atomic.Bool{}, dir: dir,
+		builtins: make(map[string]Builtin), ctx: context.Background(),
+	}
+	for _, b := range defaultBuiltins {
+		s.builtins[b.name] = b
+	}
+	return s
+}
+
+// path - путь относительно рабочего каталога шелла
+func (s *Shell) path(name string) string {
+	if s.dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
+
+// open - открытие файла относительно рабочего каталога шелла, в ошибке
+// путь остаётся таким, как его указали
+func (s *Shell) open(name string, flag int) (*os.File, error) {
+	f, err := os.OpenFile(s.path(name), flag, 0o666)
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		pathErr.Path = name
+	}
+	return f, err
+}
+
+// stdio - потоки самого шелла
+func (s *Shell) stdio() stdio {
+	var in io.Reader
+	// внешней команде отдаём только настоящий файл: иначе exec.Cmd
+	// вычитает из s.In весь оставшийся ввод шелла
+	if f, ok := s.In.(*os.File); ok {
+		in = f
+	}
+	return stdio{in: in, out: s.Out, err: s.Err}
+}
+
+// Run - центровая ф-я заупска
+func (s *Shell) Run() error {
+	return s.GetLines()
+}
+
+// cd - встроенная команда cd [каталог | -]: без аргумента переход
+// в $HOME, cd - возвращает в $OLDPWD и печатает новый каталог
+func (s *Shell) cd(args []string, out io.Writer) error {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	var dir string
+	switch {
+	case len(args) > 1:
+		return errCd
+	case len(args) == 0:
+		home, _ := s.Get("HOME")
+		if home == "" {
+			return errNoHome
+		}
+		dir = home
+	case args[0] == "-":
+		old, _ := s.Get("OLDPWD")
+		if old == "" {
+			return errNoOldpwd
+		}
+		if err := s.chdir(old); err != nil {
+			return fmt.Errorf("cd: %w", err)
+		}
+		_, err := fmt.Fprintln(out, s.dir)
+		return err
+	default:
+		dir = args[0]
+	}
+	if err := s.chdir(dir); err != nil {
+		return fmt.Errorf("cd: %w", err)
+	}
+	return nil
+}
+
+// chdir - смена рабочего каталога шелла с обновлением $PWD и $OLDPWD.
+// Каталог процесса не меняется: подоболочки в горутинах работают
+// каждая в своём
+func (s *Shell) chdir(arg string) error {
+	dir := filepath.Clean(s.path(arg))
+	info, err := os.Stat(dir)
+	switch {
+	case err != nil:
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+	case !info.IsDir():
+		err = syscall.ENOTDIR
+	default:
+		err = syscall.Access(dir, 1)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %w", arg, err)
+	}
+	s.Set("OLDPWD", s.dir)
+	s.dir = dir
+	s.Set("PWD", dir)
+	return nil
+}
+
+// pwd - напечатать полныфй путь до рабочей дериктории
+func (s *Shell) pwd(out io.Writer) error {
+	path := s.dir
+	if path == "" {
+		var err error
+		if path, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintln(out, path)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// echo - реализация linux-команды echo: без аргументов - пустая
+// строка, -n - без перевода строки в конце
+func (s *Shell) echo(printer io.Writer, args []string) error {
+	newline := "\n"
+	for len(args) > 0 && args[0] == "-n" {
+		newline, args = "", args[1:]
+	}
+	_, err := fmt.Fprint(printer, strings.Join(args, " "), newline)
+	return err
+}
+
+// GetLines - интерактивное чтение строк до \quit, exit или конца ввода.
+// Ошибку возвращает только чтение ввода, код последней команды - в s.Status
+func (s *Shell) GetLines() error {
+	var src lineReader = &scanner{Scanner: bufio.NewScanner(s.In), out: s.Out}
+	if f, ok := s.In.(*os.File); ok && isTerminal(int(f.Fd())) {
+		src = s.newEditor(f)
+	}
+	s.interactive = true
+	return s.execute(src, true)
+}
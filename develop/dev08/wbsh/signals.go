@@ -0,0 +1,93 @@
+package wbsh
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// setForeground - передача терминала группе процессов pgid. Шелл может
+// делать это и не будучи на переднем плане: SIGTTOU на время вызова
+// игнорируется
+func setForeground(tty, pgid int) error {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	id := int32(pgid)
+	return ioctl(tty, syscall.TIOCSPGRP, unsafe.Pointer(&id))
+}
+
+// handleSignals - обработка сигналов интерактивного шелла: Ctrl-C и
+// Ctrl-\ не завершают шелл, а пересылаются заданию переднего плана и
+// прерывают выполняемую команду, Ctrl-Z останавливает только задание,
+// SIGTERM игнорируется
+func (s *Shell) handleSignals() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP, syscall.SIGTERM)
+	go func() {
+		for sig := range sigs {
+			if sig == syscall.SIGTSTP || sig == syscall.SIGTERM {
+				continue
+			}
+			if sig == syscall.SIGINT {
+				s.intr.Store(true)
+			}
+			if job := s.jobs.foreground(); job != nil {
+				job.signal(sig.(syscall.Signal))
+			}
+		}
+	}()
+}
+
+// initJobControl - управление заданиями на терминале tty: шелл
+// становится лидером своей группы и забирает терминал, задания
+// переднего плана получают его на время выполнения
+func (s *Shell) initJobControl(tty int) error {
+	// лидер сеанса не может сменить группу, он уже её лидер
+	_ = syscall.Setpgid(0, 0)
+	s.pgid = syscall.Getpgrp()
+	if err := setForeground(tty, s.pgid); err != nil {
+		return err
+	}
+	termios, err := getTermios(tty)
+	if err != nil {
+		return err
+	}
+	s.tty, s.termios, s.jobControl = tty, termios, true
+	return nil
+}
+
+// takeTerminal - возврат терминала шеллу после задания переднего плана.
+// Режим терминала остановленного задания запоминается и заменяется
+// режимом шелла
+func (s *Shell) takeTerminal(job *Job) {
+	if !s.jobControl {
+		return
+	}
+	setForeground(s.tty, s.pgid)
+	if job.State() == jobStopped {
+		termios, _ := getTermios(s.tty)
+		job.mu.Lock()
+		job.termios = termios
+		job.mu.Unlock()
+	}
+	ioctl(s.tty, syscall.TCSETS, unsafe.Pointer(s.termios))
+}
+
+// giveTerminal - передача терминала продолжаемому заданию вместе
+// с его сохранённым режимом
+func (s *Shell) giveTerminal(job *Job) {
+	if !s.jobControl {
+		return
+	}
+	job.mu.Lock()
+	pgid, termios := job.pgid, job.termios
+	job.tty = s.tty
+	job.mu.Unlock()
+	if termios != nil {
+		ioctl(s.tty, syscall.TCSETS, unsafe.Pointer(termios))
+	}
+	if pgid != 0 {
+		setForeground(s.tty, pgid)
+	}
+}
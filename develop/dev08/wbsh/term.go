@@ -0,0 +1,55 @@
+package wbsh
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// ioctl - системный вызов ioctl с указателем на структуру
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// getTermios - текущий режим терминала
+func getTermios(fd int) (*syscall.Termios, error) {
+	t := &syscall.Termios{}
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(t)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// isTerminal - дескриптор открыт на терминал
+func isTerminal(fd int) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// makeRaw - перевод терминала в посимвольный режим без эха и сигналов
+// от клавиатуры. Возвращает функцию восстановления прежнего режима
+func makeRaw(fd int) (func(), error) {
+	old, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+	raw := *old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.BRKINT | syscall.ISTRIP | syscall.INPCK
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN], raw.Cc[syscall.VTIME] = 1, 0
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return func() { ioctl(fd, syscall.TCSETS, unsafe.Pointer(old)) }, nil
+}
+
+// termWidth - ширина терминала в символах, 80 если её не узнать
+func termWidth(fd int) int {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil || ws.Col == 0 {
+		return 80
+	}
+	return int(ws.Col)
+}
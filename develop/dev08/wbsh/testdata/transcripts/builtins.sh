@@ -0,0 +1,31 @@
+echo hello   world
+echo
+echo -n no newline; echo " here"
+pwd
+mkdir -p a/b
+cd a/b
+pwd
+cd -
+cd
+pwd
+pushd a
+pushd b
+dirs -v
+popd
+popd
+popd
+X=1
+export Y=2
+echo $X $Y ${Z:-default}
+unset X
+echo "[$X]"
+set -- one two
+echo $# $1 $2
+alias hi='echo hi there'
+hi
+alias
+unalias hi
+type hi cd if
+help pwd
+exit 3
+echo not reached
@@ -0,0 +1,21 @@
+no-such-command-wbsh
+echo $?
+cd /no/such/dir
+echo $?
+cd a b
+kill
+kill -BOGUS 1
+pwd extra
+echo "unterminated
+quote"
+if true; then
+echo inside
+fi
+fi
+echo $?
+echo ok; )
+false && echo no
+false || echo yes
+break
+return
+echo end
@@ -0,0 +1,22 @@
+NAME=world
+cat <<EOF
+hello $NAME
+sum: $(echo 1 2 | tr ' ' +)
+escaped \$NAME and "quotes"
+EOF
+cat <<'EOF'
+literal $NAME $(echo no)
+EOF
+	cat <<-EOF
+		tabs are stripped
+	EOF
+tr a-z A-Z <<< "here-string $NAME"
+cat <<A <<B
+first
+A
+second
+B
+{ cat; echo end; } <<EOF > out.txt
+in a block
+EOF
+cat out.txt
@@ -0,0 +1,35 @@
+sleep 0.1 &
+echo "$!" | grep -c '^[0-9][0-9]*$'
+jobs
+wait
+echo $?
+jobs
+sleep 5 &
+sleep 5 &
+jobs
+kill %1
+wait %1
+echo $?
+kill -STOP %2
+sleep 0.2
+jobs
+kill -KILL %2
+wait %2
+echo $?
+jobs
+(sleep 0.1; exit 3) &
+sleep 0.3
+wait $!
+echo $?
+sleep 0.05 &
+sleep 0.4
+echo notified
+kill %9 999999
+echo $?
+wait %7
+fg
+bg %3
+(sleep 0.1; exit 3) &
+wait
+echo $?
+fg %1 %2
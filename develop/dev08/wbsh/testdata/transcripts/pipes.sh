@@ -0,0 +1,8 @@
+echo b a c | tr ' ' '\n' | sort
+printf 'x\ny\nz\n' | wc -l
+echo one | cat | cat | tr a-z A-Z
+echo "$(echo nested | tr a-z A-Z)"
+for w in $(echo 1 2 3); do echo item $w; done | sort -r
+f() { echo in function; echo "args: $*"; }
+f a b | cat
+echo done
@@ -0,0 +1,15 @@
+echo first > out.txt
+echo second >> out.txt
+cat < out.txt
+cat out.txt nope 2> err.txt
+cat err.txt
+cat nope > all.txt 2>&1
+cat all.txt
+echo both &> both.txt
+cat both.txt
+{ echo block; echo lines; } > block.txt
+cat block.txt
+echo to stderr >&2
+cat missing.txt 2>/dev/null || echo failed with $?
+ls *.txt
+echo x > /no/such/dir/file
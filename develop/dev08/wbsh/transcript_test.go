@@ -0,0 +1,107 @@
+package wbsh
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden transcripts in testdata/transcripts")
+
+// bgPid - сообщение о запуске фонового задания, pid в нём каждый раз свой
+var bgPid = regexp.MustCompile(`(?m)^(\[\d+\]) \d+$`)
+
+// echoReader - ввод сеанса по одной строке за Read: строка попадает
+// в вывод в момент, когда шелл её читает, сразу после приглашения
+type echoReader struct {
+	lines *bufio.Reader
+	out   io.Writer
+}
+
+func (r *echoReader) Read(p []byte) (int, error) {
+	line, err := r.lines.ReadString('\n')
+	if line == "" {
+		return 0, err
+	}
+	io.WriteString(r.out, line)
+	return copy(p, line), nil
+}
+
+// firstDiff - номер первой различающейся строки и сами строки
+func firstDiff(got, want string) (int, string, string) {
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; ; i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w || i >= len(gotLines) || i >= len(wantLines) {
+			return i + 1, g, w
+		}
+	}
+}
+
+// TestTranscripts - сеансы из testdata/transcripts/*.sh выполняются
+// интерактивным шеллом, вывод вместе с приглашениями и введёнными
+// строками сравнивается с *.golden. Рабочий каталог сеанса - временный,
+// в выводе он заменяется на $WORK, pid фоновых заданий - на $PID.
+// go test -update перезаписывает *.golden
+func TestTranscripts(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "transcripts", "*.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatal("no transcripts found")
+	}
+
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), ".sh")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+
+			var out strings.Builder
+			sh := NewShell(&out, nil)
+			sh.In = &echoReader{lines: bufio.NewReader(strings.NewReader(string(input))), out: &out}
+			sh.dir = dir
+			sh.Set("HOME", dir)
+			sh.Set("PWD", dir)
+			for _, name := range []string{"PS1", "PS2", "OLDPWD", "CDPATH"} {
+				delete(sh.vars, name)
+			}
+			if err := sh.Run(); err != nil {
+				t.Fatal(err)
+			}
+			got := strings.ReplaceAll(out.String(), dir, "$WORK")
+			got = bgPid.ReplaceAllString(got, "$1 $$PID")
+
+			golden := strings.TrimSuffix(script, ".sh") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				n, gotLine, wantLine := firstDiff(got, string(want))
+				t.Errorf("%v:%v: got: %q, want: %q\n--- got\n%v", golden, n, gotLine, wantLine, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package wbsh
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// variable - переменная шелла, exported - передаётся дочерним процессам
+type variable struct {
+	value    string
+	exported bool
+}
+
+// environ - переменные окружения процесса шелла, все экспортированы
+func environ() map[string]*variable {
+	vars := make(map[string]*variable)
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && name != "" {
+			vars[name] = &variable{value: value, exported: true}
+		}
+	}
+	return vars
+}
+
+// cloneVars - копия переменных для подоболочки
+func cloneVars(vars map[string]*variable) map[string]*variable {
+	vars = maps.Clone(vars)
+	for name, v := range vars {
+		if v == nil {
+			continue
+		}
+		clone := *v
+		vars[name] = &clone
+	}
+	return vars
+}
+
+// isName - строка - допустимое имя переменной
+func isName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isNameByte(name[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Get - значение переменной или специального параметра
+func (s *Shell) Get(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(s.Status), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	case "!":
+		return s.lastBg, s.lastBg != ""
+	case "0":
+		return s.name, true
+	case "#":
+		return strconv.Itoa(len(s.args)), true
+	case "@", "*":
+		return strings.Join(s.args, " "), true
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > len(s.args) {
+			return "", false
+		}
+		return s.args[n-1], true
+	}
+	if v, ok := s.vars[name]; ok {
+		return v.value, true
+	}
+	return "", false
+}
+
+// Set - присваивание значения переменной
+func (s *Shell) Set(name, value string) {
+	if v, ok := s.vars[name]; ok {
+		v.value = value
+		return
+	}
+	s.vars[name] = &variable{value: value}
+}
+
+// names - имена переменных по алфавиту
+func (s *Shell) names() []string {
+	names := make([]string, 0, len(s.vars))
+	for name := range s.vars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Environ - экспортированные переменные в виде NAME=value для дочерних процессов
+func (s *Shell) Environ() []string {
+	env := make([]string, 0, len(s.vars))
+	for _, name := range s.names() {
+		if v := s.vars[name]; v.exported {
+			env = append(env, name+"="+v.value)
+		}
+	}
+	return env
+}
+
+// export - встроенная команда export NAME[=value]...
+func (s *Shell) export(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		for _, name := range s.names() {
+			if v := s.vars[name]; v.exported {
+				if _, err := fmt.Fprintf(out, "export %v=%v\n", name, quote(v.value)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !isName(name) {
+			return fmt.Errorf("export: '%v': not a valid identifier", arg)
+		}
+		if hasValue {
+			s.Set(name, value)
+		} else if _, ok := s.vars[name]; !ok {
+			s.Set(name, "")
+		}
+		s.vars[name].exported = true
+	}
+	return nil
+}
+
+// unset - встроенная команда unset NAME...
+func (s *Shell) unset(args []string) error {
+	for _, name := range args {
+		if !isName(name) {
+			return fmt.Errorf("unset: '%v': not a valid identifier", name)
+		}
+		delete(s.vars, name)
+	}
+	return nil
+}
+
+// env - встроенная команда env: экспортированные переменные
+func (s *Shell) env(out io.Writer) error {
+	for _, kv := range s.Environ() {
+		if _, err := fmt.Fprintln(out, kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// param - значение подстановки параметра
+func (s *Shell) param(p *ParamExp) string {
+	value, set := s.Get(p.Name)
+	if strings.HasPrefix(p.Op, ":") {
+		set = value != ""
+	}
+	switch strings.TrimPrefix(p.Op, ":") {
+	case "-":
+		if !set {
+			return s.expandString(p.Word)
+		}
+	case "=":
+		if !set {
+			value = s.expandString(p.Word)
+			if isName(p.Name) {
+				s.Set(p.Name, value)
+			}
+		}
+	case "+":
+		if set {
+			return s.expandString(p.Word)
+		}
+		return ""
+	}
+	return value
+}
+
+// substitute - подстановка команды: её вывод без завершающих переводов
+// строки. Команда выполняется в подоболочке, $? - её код возврата
+func (s *Shell) substitute(c *CmdSubst) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintln(s.Err, err)
+		s.Status = 1
+		return ""
+	}
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		r.Close()
+		out <- data
+	}()
+	std := s.stdio()
+	std.out, std.job = w, newJob(c.Text, false)
+	std.job.group = s.groupJobs
+	sub := s.subshell()
+	status := sub.run(c.List, std)
+	w.Close()
+	data := <-out
+	s.Status = status
+	s.substs++
+	return strings.TrimRight(string(data), "\n")
+}
+
+// substText - значение подстановки параметра или команды
+func (s *Shell) substText(p WordPart) string {
+	if p.Cmd != nil {
+		return s.substitute(p.Cmd)
+	}
+	return s.param(p.Param)
+}
+
+// expandString - раскрытие слова в одну строку без деления на поля
+func (s *Shell) expandString(w Word) string {
+	var b strings.Builder
+	for _, p := range w {
+		if p.isSubst() {
+			b.WriteString(s.substText(p))
+		} else {
+			b.WriteString(p.Text)
+		}
+	}
+	return b.String()
+}
+
+// isIFS - разделитель полей при делении результата подстановки
+func isIFS(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// expandWord - раскрытие слова в поля: тильда в начале слова,
+// подстановки параметров и команд вне кавычек делятся на поля
+// по пробельным символам,
+// поля с * ? [ вне кавычек заменяются подходящими путями
+func (s *Shell) expandWord(w Word) []string {
+	var (
+		fields   []string
+		cur, pat strings.Builder // поле и оно же как шаблон
+		has      bool
+		glob     bool
+	)
+	flush := func() {
+		if has {
+			matches := []string(nil)
+			if glob {
+				matches = s.glob(pat.String())
+			}
+			if len(matches) == 0 {
+				matches = []string{cur.String()}
+			}
+			fields = append(fields, matches...)
+		}
+		cur.Reset()
+		pat.Reset()
+		has, glob = false, false
+	}
+	// add - текст поля; вне кавычек символы шаблона действуют
+	add := func(text string, quoted bool) {
+		cur.WriteString(text)
+		if quoted {
+			pat.WriteString(escapePattern(text))
+		} else {
+			pat.WriteString(text)
+			glob = glob || strings.ContainsAny(text, "*?[")
+		}
+	}
+
+	for _, p := range s.expandTilde(w) {
+		if p.Param != nil && p.Quoted && p.Param.Name == "@" && p.Param.Op == "" {
+			// "$@" - каждый позиционный параметр отдельным полем
+			for i, arg := range s.args {
+				if i > 0 {
+					flush()
+				}
+				add(arg, true)
+				has = true
+			}
+			continue
+		}
+		if !p.isSubst() || p.Quoted {
+			text := p.Text
+			if p.isSubst() {
+				text = s.substText(p)
+			}
+			add(text, p.Quoted)
+			has = has || p.Quoted || text != ""
+			continue
+		}
+		value := s.substText(p)
+		start := 0
+		for i := 0; i <= len(value); i++ {
+			if i < len(value) && !isIFS(value[i]) {
+				continue
+			}
+			if i > start {
+				add(value[start:i], false)
+				has = true
+			}
+			if i < len(value) {
+				flush()
+			}
+			start = i + 1
+		}
+	}
+	flush()
+	return fields
+}
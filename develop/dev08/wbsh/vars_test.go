@@ -0,0 +1,50 @@
+package wbsh
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandWord(t *testing.T) {
+	sh := NewShell(io.Discard, strings.NewReader(""))
+	sh.vars = map[string]*variable{
+		"X":     {value: " a  b "},
+		"EMPTY": {value: ""},
+	}
+	sh.Status = 2
+
+	testcases := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "Unquoted parameter is split", input: `$X`, want: []string{"a", "b"}},
+		{name: "Quoted parameter is one field", input: `"$X"`, want: []string{" a  b "}},
+		{name: "Split joins with neighbours", input: `[$X]`, want: []string{"[", "a", "b", "]"}},
+		{name: "Empty unquoted parameter vanishes", input: `$EMPTY`, want: nil},
+		{name: "Empty quoted parameter stays", input: `"$EMPTY"`, want: []string{""}},
+		{name: "Default value", input: `${NOPE:-x}${EMPTY-y}`, want: []string{"x"}},
+		{name: "Alternative value", input: `${X:+set}${EMPTY:+no}`, want: []string{"set"}},
+		{name: "Exit status", input: `$?`, want: []string{"2"}},
+		{name: "Single quotes", input: `'$X'`, want: []string{"$X"}},
+		{name: "Unquoted command output is split", input: `$(echo a  b)`, want: []string{"a", "b"}},
+		{name: "Quoted command output", input: `"$(echo a; echo; echo)"`, want: []string{"a"}},
+		{name: "Backquotes", input: "x`echo \\`echo y\\``", want: []string{"xy"}},
+		{name: "Substitution runs in subshell", input: `$(cd /; X=1; pwd)$X`, want: []string{"/", "a", "b"}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := Parse("echo " + tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := sh.expandWord(list.Stmts[0].Node.(*Command).Args[1])
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %q, want: %q", got, tc.want)
+			}
+		})
+	}
+}
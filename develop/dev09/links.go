@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gocolly/colly"
+	"golang.org/x/net/html"
+)
+
+// cssLink - ссылки в CSS: url(...) в кавычках и без, @import "..."
+var cssLink = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// cssLinks - замена ссылок в CSS на результат fn, остальной текст
+// не меняется
+func cssLinks(css string, fn func(link string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range cssLink.FindAllStringSubmatchIndex(css, -1) {
+		for g := 2; g < len(m); g += 2 {
+			if m[g] < 0 {
+				continue
+			}
+			b.WriteString(css[last:m[g]])
+			b.WriteString(fn(css[m[g]:m[g+1]]))
+			last = m[g+1]
+			break
+		}
+	}
+	b.WriteString(css[last:])
+	return b.String()
+}
+
+// srcsetLinks - замена адресов в srcset: "адрес [дескриптор], ..."
+func srcsetLinks(srcset string, fn func(link string) string) string {
+	items := strings.Split(srcset, ",")
+	for i, item := range items {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = fn(fields[0])
+		items[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(items, ", ")
+}
+
+// htmlLinks - замена ссылок в атрибутах href, src, srcset, style и в
+// <style> на результат fn, которому передаётся и тег со ссылкой. Теги без
+// изменений и остальной текст документа остаются как были
+func htmlLinks(doc []byte, fn func(t *html.Token, attr, link string) string) []byte {
+	var b bytes.Buffer
+	var style *html.Token
+	z := html.NewTokenizer(bytes.NewReader(doc))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return b.Bytes()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			// Token приводит имя тега к нижнему регистру прямо в буфере
+			raw := string(z.Raw())
+			t := z.Token()
+			style = nil
+			if t.Data == "style" && tt == html.StartTagToken {
+				style = &t
+			}
+			changed := false
+			for i, a := range t.Attr {
+				link := func(link string) string { return fn(&t, a.Key, link) }
+				val := a.Val
+				switch a.Key {
+				case "href", "src":
+					val = link(val)
+				case "srcset":
+					val = srcsetLinks(val, link)
+				case "style":
+					val = cssLinks(val, link)
+				}
+				if val != a.Val {
+					t.Attr[i].Val = val
+					changed = true
+				}
+			}
+			if changed {
+				b.WriteString(t.String())
+			} else {
+				b.WriteString(raw)
+			}
+		case html.TextToken:
+			if style == nil {
+				b.Write(z.Raw())
+				break
+			}
+			t := style
+			b.WriteString(cssLinks(string(z.Raw()), func(link string) string { return fn(t, "", link) }))
+		default:
+			style = nil
+			b.Write(z.Raw())
+		}
+	}
+}
+
+// ConvertLinks - замена ссылок в сохранённых HTML и CSS после обхода:
+// на скачанные файлы - относительными путями, на остальные - полными
+// адресами, чтобы копия открывалась без сети
+func (w *Wget) ConvertLinks() {
+	for _, f := range w.files {
+		if !f.html && !f.css {
+			continue
+		}
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			fmt.Fprintf(w.Log, "wget: %v\n", err)
+			w.fail(exitIO)
+			continue
+		}
+		convert := func(link string) string { return w.localLink(f, link) }
+		if f.html {
+			data = htmlLinks(data, func(_ *html.Token, _, link string) string { return convert(link) })
+		} else {
+			data = []byte(cssLinks(string(data), convert))
+		}
+		if err := os.WriteFile(f.path, data, 0o644); err != nil {
+			fmt.Fprintf(w.Log, "wget: %v\n", err)
+			w.fail(exitIO)
+			continue
+		}
+		w.logf("convert: [%v]\n", f.path)
+	}
+}
+
+// localLink - ссылка из файла f: относительный путь к локальной копии
+// или полный адрес. Якоря, data: и mailto:, а также ссылки, которые уже
+// ведут на сохранённый файл, не меняются
+func (w *Wget) localLink(f savedFile, link string) string {
+	if strings.TrimSpace(link) == "" || strings.HasPrefix(link, "#") {
+		return link
+	}
+	u, err := f.url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+		return link
+	}
+	fragment := u.Fragment
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	local, ok := w.local[u.String()]
+	if !ok && w.isLocal(f, link) {
+		return link
+	}
+	if !ok {
+		u.Fragment = fragment
+		return u.String()
+	}
+	rel, err := filepath.Rel(filepath.Dir(f.path), local)
+	if err != nil {
+		return link
+	}
+	// URL.String экранирует путь и не даёт принять "host:port/..." за схему
+	return (&url.URL{Path: filepath.ToSlash(rel), Fragment: fragment}).String()
+}
+
+// isLocal - относительная ссылка из файла f на скачанный файл
+func (w *Wget) isLocal(f savedFile, link string) bool {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return false
+	}
+	_, ok := w.saved[filepath.Join(filepath.Dir(f.path), filepath.FromSlash(u.Path))]
+	return ok
+}
+
+// requisiteRels - значения rel у <link>, указывающие на ресурсы страницы
+var requisiteRels = []string{"stylesheet", "icon", "apple-touch-icon", "preload", "modulepreload", "manifest"}
+
+// isRequisite - ссылка нужна для показа страницы: link[href] со стилями
+// или иконкой, script[src], img и source, url() и @import во встроенном CSS
+func isRequisite(t *html.Token, attr string) bool {
+	switch {
+	case attr == "" || attr == "style":
+		return true
+	case t.Data == "link" && attr == "href":
+		for _, a := range t.Attr {
+			if a.Key != "rel" {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.ToLower(a.Val)) {
+				if slices.Contains(requisiteRels, rel) {
+					return true
+				}
+			}
+		}
+		return false
+	case t.Data == "script":
+		return attr == "src"
+	case t.Data == "img", t.Data == "source":
+		return attr == "src" || attr == "srcset"
+	}
+	return false
+}
+
+// PageRequisites - ресурсы скачанных HTML и CSS (стили, скрипты,
+// картинки, шрифты) в очередь на той же глубине, с хоста страницы или -D
+func (w *Wget) PageRequisites() {
+	w.colly.OnResponse(func(r *colly.Response) {
+		depth, _ := r.Ctx.GetAny("depth").(int)
+		add := func(link string) string {
+			w.addRequisite(r.Request, link, depth)
+			return link
+		}
+		isHTML, isCSS := fileType(r, r.Request.URL.Path)
+		switch {
+		case isHTML:
+			htmlLinks(r.Body, func(t *html.Token, attr, link string) string {
+				if isRequisite(t, attr) {
+					add(link)
+				}
+				return link
+			})
+		case isCSS:
+			cssLinks(string(r.Body), add)
+		}
+	})
+}
+
+// addRequisite - ресурс страницы в очередь, если он ещё не скачан
+func (w *Wget) addRequisite(r *colly.Request, link string, depth int) {
+	u, err := url.Parse(r.AbsoluteURL(strings.TrimSpace(link)))
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	if u.Host != w.Domain.Host && !slices.Contains(w.Domains, u.Host) {
+		return
+	}
+	absLink := u.String()
+	if w.isVisit[absLink] {
+		return
+	}
+	w.isVisit[absLink] = true
+	w.queue = append(w.queue, page{url: absLink, depth: depth, requisite: true})
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
-	"bufio"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/gocolly/colly"
@@ -15,110 +17,395 @@ import (
 /*
 	Реализовать утилиту wget с возможностью скачивать сайты целиком.
 
+	wget [-r [-l N] [-np]] [-p [-D HOSTS]] [-k] [-O FILE] [-P DIR] [-nc] [-q | -v] [--user-agent UA] URL...
 */
 
+var (
+	errNoURL  = errors.New("missing URL")
+	errOutput = errors.New("-O cannot be used with -r or -p")
+)
+
+// коды выхода как у wget
+const (
+	exitUsage   = 2
+	exitIO      = 3
+	exitNetwork = 4
+	exitServer  = 8
+)
+
 type Wget struct {
-	colly   *colly.Collector
-	Dir     string
-	Domain  *url.URL
-	isVisit map[string]bool
+	colly     *colly.Collector
+	Dir       string   // -P: каталог для сохранения
+	Domain    *url.URL // скачиваемый сейчас адрес из командной строки
+	URLs      []*url.URL
+	Output    string // -O: все документы в один файл, "-" - стандартный вывод
+	Recursive bool
+	Depth     int // -l: глубина рекурсии, 0 - без ограничения
+	NoParent  bool
+	NoClobber bool
+	Quiet     bool
+	Verbose   bool
+	UserAgent string
+	Convert   bool     // -k: ссылки в сохранённых страницах на локальные копии
+	Requisite bool     // -p: скачивать стили, скрипты и картинки страниц
+	Domains   []string // -D: другие хосты (CDN), с которых берутся ресурсы страниц
+	Log       io.Writer
+	isVisit   map[string]bool
+	output    io.Writer
+	status    int
+	failed    bool // ошибка при скачивании текущего адреса
+	queue     []page
+	local     map[string]string // адрес - сохранённый файл
+	files     []savedFile
+	saved     map[string]int // сохранённый файл - его индекс в files
+}
+
+// savedFile - скачанный файл и его тип для замены ссылок
+type savedFile struct {
+	url       *url.URL
+	path      string
+	html, css bool
+}
+
+// page - страница в очереди обхода и её глубина от стартовой. Ресурсы
+// страницы (requisite) скачиваются на её глубине, ссылки в них не обходятся
+type page struct {
+	url       string
+	depth     int
+	requisite bool
 }
 
 func NewWget() *Wget {
 	return &Wget{
+		Dir:     ".",
+		Depth:   5,
+		Log:     os.Stderr,
 		isVisit: make(map[string]bool),
+		local:   make(map[string]string),
+		saved:   make(map[string]int),
 	}
 }
 
 func main() {
 	wget := NewWget()
-	wget.Init()
-	wget.MakeDir()
-	wget.VisitPage()
-	wget.SavePage()
-	wget.isVisit[wget.Domain.String()] = true
-	err := wget.colly.Visit(wget.Domain.String())
-	if err != nil {
-		log.Fatal(err)
+	if err := wget.Init(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(exitUsage)
 	}
-	wget.colly.Wait()
-	fmt.Printf("Save [%v] complete\n", wget.Domain.String())
+	os.Exit(wget.Run())
 }
 
-// Init - инициализация с базовыми настройками
-func (w *Wget) Init() {
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		log.Fatal(err)
+// Init - разбор командной строки. Флаги можно указывать и после адресов,
+// адрес без схемы считается http://
+func (w *Wget) Init(args []string) error {
+	fl := flag.NewFlagSet("wget", flag.ContinueOnError)
+	fl.SetOutput(w.Log)
+	fl.StringVar(&w.Output, "O", w.Output, "write documents to `file` (- for stdout)")
+	fl.StringVar(&w.Dir, "P", w.Dir, "save files to `prefix`/...")
+	fl.BoolVar(&w.Recursive, "r", w.Recursive, "download recursively")
+	fl.IntVar(&w.Depth, "l", w.Depth, "maximum recursion `depth` (0 for infinite)")
+	fl.BoolVar(&w.NoParent, "np", w.NoParent, "don't ascend to the parent directory")
+	fl.BoolVar(&w.NoClobber, "nc", w.NoClobber, "don't overwrite existing files")
+	fl.BoolVar(&w.Quiet, "q", w.Quiet, "quiet (no output)")
+	fl.BoolVar(&w.Verbose, "v", w.Verbose, "verbose output")
+	fl.StringVar(&w.UserAgent, "user-agent", w.UserAgent, "identify as `agent` instead of the default")
+	fl.BoolVar(&w.Convert, "k", w.Convert, "make links in downloaded HTML or CSS point to local files")
+	fl.BoolVar(&w.Convert, "convert-links", w.Convert, "same as -k")
+	fl.BoolVar(&w.Requisite, "p", w.Requisite, "get all images, styles and scripts needed to display the pages")
+	fl.BoolVar(&w.Requisite, "page-requisites", w.Requisite, "same as -p")
+	fl.Func("D", "comma-separated `hosts` to get page requisites from, e.g. CDNs", w.addDomains)
+	fl.Func("domains", "same as -D", w.addDomains)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: wget [OPTION]... [URL]...")
+		fl.PrintDefaults()
 	}
-	w.Domain, err = url.ParseRequestURI(strings.TrimSuffix(input, "\n"))
-	if err != nil {
-		log.Fatal(err)
+	usage := func(err error) error {
+		fmt.Fprintf(fl.Output(), "wget: %v\n", err)
+		fl.Usage()
+		return err
+	}
+
+	var urls []string
+	for {
+		if err := fl.Parse(args); err != nil {
+			return err
+		}
+		if fl.NArg() == 0 {
+			break
+		}
+		urls = append(urls, fl.Arg(0))
+		args = fl.Args()[1:]
+	}
+	if len(urls) == 0 {
+		return usage(errNoURL)
+	}
+	if (w.Recursive || w.Requisite) && w.Output != "" {
+		return usage(errOutput)
+	}
+	for _, raw := range urls {
+		u, err := parseURL(raw)
+		if err != nil {
+			return usage(err)
+		}
+		w.URLs = append(w.URLs, u)
+	}
+	return nil
+}
+
+// addDomains - хосты из списка через запятую для -D
+func (w *Wget) addDomains(list string) error {
+	for _, host := range strings.Split(list, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			w.Domains = append(w.Domains, host)
+		}
 	}
-	w.Dir = "test_sites/"
-	w.colly = colly.NewCollector(colly.AllowedDomains(w.Domain.Host))
+	return nil
 }
 
-// MakeDir - создание базовой директории
-func (w *Wget) MakeDir() {
-	domain := w.Domain.Hostname()
-	if err := os.MkdirAll(w.Dir+domain, os.ModePerm); err != nil {
-		log.Fatal(err)
+// parseURL - адрес из командной строки, по умолчанию http://, пустой
+// путь - корень сайта
+func parseURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
 	}
-	err := os.Chdir(w.Dir + domain)
+	u, err := url.Parse(raw)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%v: unsupported scheme", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%v: invalid URL", raw)
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u, nil
+}
+
+// Run - скачивание всех адресов: страница целиком с -r, иначе один файл.
+// Возвращает код выхода
+func (w *Wget) Run() int {
+	switch w.Output {
+	case "":
+	case "-":
+		w.output = os.Stdout
+	default:
+		f, err := os.Create(w.Output)
+		if err != nil {
+			fmt.Fprintf(w.Log, "wget: %v\n", err)
+			return exitIO
+		}
+		defer f.Close()
+		w.output = f
 	}
 
+	for _, u := range w.URLs {
+		w.Domain, w.failed = u, false
+		w.colly = w.NewCollector()
+		if w.Recursive {
+			w.VisitPage()
+		}
+		if w.Requisite {
+			w.PageRequisites()
+		}
+		w.SavePage()
+		w.isVisit[u.String()] = true
+		// обход в ширину, как у wget: страница скачивается на наименьшей глубине
+		w.queue = append(w.queue[:0], page{url: u.String()})
+		for len(w.queue) > 0 {
+			p := w.queue[0]
+			w.queue = w.queue[1:]
+			ctx := colly.NewContext()
+			ctx.Put("depth", p.depth)
+			ctx.Put("url", p.url)
+			ctx.Put("requisite", p.requisite)
+			// ошибки запроса уже учтены в OnError
+			_ = w.colly.Request("GET", p.url, nil, ctx, nil)
+		}
+		if !w.failed {
+			w.logf("Save [%v] complete\n", u)
+		}
+	}
+	if w.Convert && w.output == nil {
+		w.ConvertLinks()
+	}
+	return w.status
 }
 
-// VisitPage - посещение страниц по ссылкам типа <a>
-func (w *Wget) VisitPage() {
-	w.colly.OnHTML("a[href]", func(e *colly.HTMLElement) {
-		link := e.Attr("href")
-		absLink := e.Request.AbsoluteURL(link)
-		if !w.isVisit[absLink] {
-			err := w.colly.Visit(absLink)
-			if err != nil {
-				fmt.Printf("error page [%v]: %v\n", absLink, err)
+// NewCollector - коллектор для текущего адреса: только его хост и хосты
+// -D, заголовок User-Agent и обработка ошибок
+func (w *Wget) NewCollector() *colly.Collector {
+	hosts := append([]string{w.Domain.Host}, w.Domains...)
+	c := colly.NewCollector(colly.AllowedDomains(hosts...), colly.MaxBodySize(0))
+	if w.UserAgent != "" {
+		c.UserAgent = w.UserAgent
+	}
+
+	c.OnRequest(func(r *colly.Request) {
+		if w.NoClobber && !w.Recursive && !w.Requisite && w.output == nil {
+			if _, fullPath := w.GetPath(r.URL); exists(fullPath) {
+				w.logf("file [%v] already there; not retrieving\n", fullPath)
+				r.Abort()
+				return
 			}
-			w.isVisit[absLink] = true
 		}
+		if w.Verbose {
+			w.logf("get: [%v]\n", r.URL)
+		}
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		if !w.Quiet {
+			fmt.Fprintf(w.Log, "error page [%v]: %v\n", r.Request.URL, err)
+		}
+		if r.StatusCode >= 400 {
+			w.fail(exitServer)
+		} else {
+			w.fail(exitNetwork)
+		}
+	})
+	return c
+}
 
+// VisitPage - посещение страниц по ссылкам типа <a> того же хоста
+// не глубже -l
+func (w *Wget) VisitPage() {
+	w.colly.OnHTML("a[href]", func(e *colly.HTMLElement) {
+		if requisite, _ := e.Request.Ctx.GetAny("requisite").(bool); requisite {
+			return
+		}
+		link, err := url.Parse(e.Request.AbsoluteURL(e.Attr("href")))
+		if err != nil || link.Host == "" {
+			return
+		}
+		link.Fragment = ""
+		absLink := link.String()
+		if w.isVisit[absLink] || link.Host != w.Domain.Host || w.NoParent && !w.underStart(link) {
+			return
+		}
+		depth, _ := e.Request.Ctx.GetAny("depth").(int)
+		if w.Depth > 0 && depth >= w.Depth {
+			return
+		}
+		w.isVisit[absLink] = true
+		w.queue = append(w.queue, page{url: absLink, depth: depth + 1})
 	})
 }
 
-// GetPath - получение пути для схранения файлов
-func (w *Wget) GetPath(currentPath string) (pathDir string, fullPath string) {
-	fullPath = w.Dir + w.Domain.Host + currentPath
-	pathDir = fullPath
+// underStart - ссылка не выходит выше каталога стартового адреса (-np)
+func (w *Wget) underStart(link *url.URL) bool {
+	dir := w.Domain.Path
+	if i := strings.LastIndex(dir, "/"); i != -1 {
+		dir = dir[:i+1]
+	}
+	return strings.HasPrefix(link.Path, dir) || link.Path+"/" == dir
+}
 
-	if path.Ext(currentPath) == "" { // если путь без расширения, то добавляем '/'
-		if fullPath[len(fullPath)-1] != '/' {
-			fullPath += "/"
+// GetPath - получение пути для схранения файлов: при -r и -p дерево
+// сайта в каталоге хоста, иначе файл по последней компоненте адреса.
+// Строка запроса остаётся в имени файла
+func (w *Wget) GetPath(u *url.URL) (pathDir string, fullPath string) {
+	urlPath := path.Clean("/" + u.Path)
+	if !w.Recursive && !w.Requisite {
+		name := path.Base(urlPath)
+		if strings.HasSuffix(u.Path, "/") || name == "/" {
+			name = "index.html"
 		}
-		fullPath += "index.html"
+		fullPath = filepath.Join(w.Dir, name)
+		return filepath.Dir(fullPath), fullPath + querySuffix(u)
 	}
-	if index := strings.LastIndex(fullPath, "/"); index != -1 {
-		pathDir = fullPath[:index]
+
+	fullPath = filepath.Join(w.Dir, u.Host, filepath.FromSlash(urlPath))
+	if path.Ext(urlPath) == "" { // если путь без расширения, то это каталог
+		fullPath = filepath.Join(fullPath, "index.html")
 	}
-	return
+	return filepath.Dir(fullPath), fullPath + querySuffix(u)
+}
+
+// querySuffix - строка запроса в конце имени файла, как у wget:
+// page?id=1 и page?id=2 сохраняются в разные файлы. "/" в запросе
+// экранируется, чтобы не получались каталоги
+func querySuffix(u *url.URL) string {
+	if u.RawQuery == "" {
+		return ""
+	}
+	return "?" + strings.ReplaceAll(u.RawQuery, "/", "%2F")
 }
 
 // SavePage - сохранение страницы в нужные пути
 func (w *Wget) SavePage() {
 	w.colly.OnResponse(func(r *colly.Response) {
-		pathDir, fullPath := w.GetPath(r.Request.URL.Path)
-		fmt.Printf("save: [%v] to [%v]\n", r.Request.URL.Path, pathDir)
-		//if _, err := os.Stat(pathDir); err != nil {
+		if w.output != nil {
+			if _, err := w.output.Write(r.Body); err != nil {
+				fmt.Fprintf(w.Log, "wget: %v\n", err)
+				w.fail(exitIO)
+			}
+			return
+		}
+		pathDir, fullPath := w.GetPath(r.Request.URL)
+		// по исходному адресу ссылки, и по конечному после перенаправлений
+		w.local[r.Request.URL.String()] = fullPath
+		if link := r.Ctx.Get("url"); link != "" {
+			w.local[link] = fullPath
+		}
+		if w.NoClobber && exists(fullPath) {
+			w.logf("file [%v] already there; not overwriting\n", fullPath)
+			return
+		}
 		if err := os.MkdirAll(pathDir, os.ModePerm); err != nil {
-			log.Fatalln(err, 1)
+			fmt.Fprintf(w.Log, "wget: %v\n", err)
+			w.fail(exitIO)
+			return
 		}
-		//}
-		err := r.Save(fullPath)
-		if err != nil {
+		if err := r.Save(fullPath); err != nil {
+			fmt.Fprintf(w.Log, "wget: %v\n", err)
+			w.fail(exitIO)
+			return
+		}
+		w.logf("save: [%v] to [%v]\n", r.Request.URL, fullPath)
+
+		isHTML, isCSS := fileType(r, strings.TrimSuffix(fullPath, querySuffix(r.Request.URL)))
+		f := savedFile{url: r.Request.URL, path: fullPath, html: isHTML, css: isCSS}
+		// / и /index.html пишутся в один файл: в files он остаётся один,
+		// с адресом последнего сохранения, и ссылки в нём меняются один раз
+		if i, ok := w.saved[fullPath]; ok {
+			w.files[i] = f
 			return
 		}
+		w.saved[fullPath] = len(w.files)
+		w.files = append(w.files, f)
 	})
 }
+
+// fileType - HTML или CSS по заголовку Content-Type или расширению
+func fileType(r *colly.Response, name string) (isHTML, isCSS bool) {
+	contentType := strings.ToLower(r.Headers.Get("Content-Type"))
+	ext := strings.ToLower(path.Ext(name))
+	isHTML = strings.Contains(contentType, "html") || ext == ".html" || ext == ".htm"
+	isCSS = strings.Contains(contentType, "css") || ext == ".css"
+	return
+}
+
+// logf - сообщение о ходе работы, кроме режима -q
+func (w *Wget) logf(format string, args ...any) {
+	if !w.Quiet {
+		fmt.Fprintf(w.Log, format, args...)
+	}
+}
+
+// fail - запоминание кода ошибки; как у wget, меньший код важнее
+func (w *Wget) fail(code int) {
+	w.failed = true
+	if w.status == 0 || code < w.status {
+		w.status = code
+	}
+}
+
+// exists - файл уже есть на диске
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
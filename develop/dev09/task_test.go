@@ -0,0 +1,368 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newSite - тестовый сайт из пар путь - содержимое
+func newSite(t *testing.T, pages map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(rw, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".css") {
+			rw.Header().Set("Content-Type", "text/css")
+		} else {
+			rw.Header().Set("Content-Type", "text/html")
+		}
+		io.WriteString(rw, page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// savedFiles - файлы в dir относительно него
+func savedFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// options - флаги Wget, которые задаёт Init
+type options struct {
+	Dir, Output, UserAgent                         string
+	Depth                                          int
+	Recursive, NoParent, NoClobber, Quiet, Verbose bool
+}
+
+func TestInit(t *testing.T) {
+	testCases := []struct {
+		desc string
+		args []string
+		want options
+		urls []string
+		err  bool
+	}{
+		{desc: "defaults", args: []string{"example.com"}, want: options{Dir: ".", Depth: 5}, urls: []string{"http://example.com/"}},
+		{
+			desc: "flags after url",
+			args: []string{"-r", "https://a.com/x/", "-l", "2", "-np", "-P", "out", "b.com", "--user-agent=ua", "-q"},
+			want: options{Dir: "out", Depth: 2, Recursive: true, NoParent: true, UserAgent: "ua", Quiet: true},
+			urls: []string{"https://a.com/x/", "http://b.com/"},
+		},
+		{desc: "output", args: []string{"-O", "-", "-nc", "-v", "a.com"}, want: options{Dir: ".", Depth: 5, Output: "-", NoClobber: true, Verbose: true}, urls: []string{"http://a.com/"}},
+		{desc: "no url", args: []string{"-r"}, err: true},
+		{desc: "output with recursion", args: []string{"-r", "-O", "x", "a.com"}, err: true},
+		{desc: "bad scheme", args: []string{"ftp://a.com"}, err: true},
+		{desc: "unknown flag", args: []string{"-z", "a.com"}, err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			w := NewWget()
+			w.Log = io.Discard
+			err := w.Init(tC.args)
+			if (err != nil) != tC.err {
+				t.Fatalf("err: %v, want error: %v", err, tC.err)
+			}
+			if tC.err {
+				return
+			}
+			var urls []string
+			for _, u := range w.URLs {
+				urls = append(urls, u.String())
+			}
+			if !slices.Equal(urls, tC.urls) {
+				t.Errorf("urls: %v, want: %v", urls, tC.urls)
+			}
+			got := options{Dir: w.Dir, Depth: w.Depth, Output: w.Output, Recursive: w.Recursive, NoParent: w.NoParent,
+				NoClobber: w.NoClobber, Quiet: w.Quiet, Verbose: w.Verbose, UserAgent: w.UserAgent}
+			if got != tC.want {
+				t.Errorf("got: %+v, want: %+v", got, tC.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv := newSite(t, map[string]string{
+		"/":               `<a href="/docs/">docs</a> <a href="other/x.html#top">x</a>`,
+		"/docs/":          `<a href="sub/">sub</a> <a href="../other/x.html">up</a> <a href="http://example.invalid/">ext</a>`,
+		"/docs/sub/":      `<a href="/">home</a> <a href="deep/">deep</a>`,
+		"/docs/sub/deep/": `deep`,
+		"/other/x.html":   `x`,
+	})
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	testCases := []struct {
+		desc   string
+		args   []string
+		want   []string
+		status int
+	}{
+		{
+			desc: "single file",
+			args: []string{srv.URL + "/other/x.html", srv.URL + "/docs/"},
+			want: []string{"index.html", "x.html"},
+		},
+		{
+			desc: "query string",
+			args: []string{srv.URL + "/other/x.html?id=1", srv.URL + "/other/x.html?id=2", srv.URL + "/docs/?p=a/b"},
+			want: []string{"index.html?p=a%2Fb", "x.html?id=1", "x.html?id=2"},
+		},
+		{
+			desc: "recursive",
+			args: []string{"-r", srv.URL + "/"},
+			want: []string{host + "/docs/index.html", host + "/docs/sub/deep/index.html", host + "/docs/sub/index.html", host + "/index.html", host + "/other/x.html"},
+		},
+		{
+			desc: "depth",
+			args: []string{"-r", "-l", "1", srv.URL + "/"},
+			want: []string{host + "/docs/index.html", host + "/index.html", host + "/other/x.html"},
+		},
+		{
+			desc: "no parent",
+			args: []string{"-r", "-np", srv.URL + "/docs/"},
+			want: []string{host + "/docs/index.html", host + "/docs/sub/deep/index.html", host + "/docs/sub/index.html"},
+		},
+		{
+			desc:   "not found",
+			args:   []string{srv.URL + "/missing", srv.URL + "/other/x.html"},
+			want:   []string{"x.html"},
+			status: exitServer,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			w := NewWget()
+			w.Log = io.Discard
+			if err := w.Init(append([]string{"-P", dir}, tC.args...)); err != nil {
+				t.Fatal(err)
+			}
+			if status := w.Run(); status != tC.status {
+				t.Errorf("status: %v, want: %v", status, tC.status)
+			}
+			if got := savedFiles(t, dir); !slices.Equal(got, tC.want) {
+				t.Errorf("files: %v, want: %v", got, tC.want)
+			}
+		})
+	}
+}
+
+func TestNoClobber(t *testing.T) {
+	srv := newSite(t, map[string]string{"/x.html": "new"})
+	dir := t.TempDir()
+	name := filepath.Join(dir, "x.html")
+	if err := os.WriteFile(name, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWget()
+	w.Log = io.Discard
+	if err := w.Init([]string{"-nc", "-P", dir, srv.URL + "/x.html"}); err != nil {
+		t.Fatal(err)
+	}
+	w.Run()
+	if data, _ := os.ReadFile(name); string(data) != "old" {
+		t.Errorf("file overwritten: %q", data)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	srv := newSite(t, map[string]string{"/a": "a\n", "/b": "b\n"})
+	out := filepath.Join(t.TempDir(), "all.txt")
+
+	w := NewWget()
+	w.Log = io.Discard
+	if err := w.Init([]string{"-O", out, srv.URL + "/a", srv.URL + "/b"}); err != nil {
+		t.Fatal(err)
+	}
+	if status := w.Run(); status != 0 {
+		t.Errorf("status: %v", status)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "a\nb\n" {
+		t.Errorf("output: %q", data)
+	}
+}
+
+func TestCSSLinks(t *testing.T) {
+	testCases := []struct {
+		desc string
+		css  string
+		want string
+	}{
+		{desc: "quotes", css: `a{b:url("x.png")} c{d:url('y.png')}`, want: `a{b:url("[x.png]")} c{d:url('[y.png]')}`},
+		{desc: "bare", css: `a{b:URL( x.png )}`, want: `a{b:URL( [x.png] )}`},
+		{desc: "import", css: `@import "a.css"; @import url(b.css);`, want: `@import "[a.css]"; @import url([b.css]);`},
+		{desc: "no links", css: `a{color:red}`, want: `a{color:red}`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := cssLinks(tC.css, func(link string) string { return "[" + link + "]" })
+			if got != tC.want {
+				t.Errorf("got: %q, want: %q", got, tC.want)
+			}
+		})
+	}
+}
+
+func TestConvertLinks(t *testing.T) {
+	srv := newSite(t, map[string]string{
+		"/": `<a href="/s.css">css</a><a href="/docs/#top">docs</a> <IMG SRC="/missing.png">`,
+		"/docs/": `<a href="/">home</a> <a href="http://example.invalid/x">ext</a> <a href="#top">top</a>` +
+			` <img srcset="../s.css 1x, /missing.png 2x">`,
+		"/s.css": `body{background:url("/docs/")}`,
+	})
+	dir := t.TempDir()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	w := NewWget()
+	w.Log = io.Discard
+	if err := w.Init([]string{"-r", "-k", "-P", dir, srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if status := w.Run(); status != 0 {
+		t.Errorf("status: %v", status)
+	}
+
+	want := map[string]string{
+		"index.html": `<a href="s.css">css</a><a href="docs/index.html#top">docs</a> <img src="` + srv.URL + `/missing.png">`,
+		"docs/index.html": `<a href="../index.html">home</a> <a href="http://example.invalid/x">ext</a> <a href="#top">top</a>` +
+			` <img srcset="../s.css 1x, ` + srv.URL + `/missing.png 2x">`,
+		"s.css": `body{background:url("docs/index.html")}`,
+	}
+	for name, page := range want {
+		data, err := os.ReadFile(filepath.Join(dir, host, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != page {
+			t.Errorf("%v: got: %q, want: %q", name, data, page)
+		}
+	}
+}
+
+func TestConvertLinksSameFile(t *testing.T) {
+	index := `<a href="/sub/">sub</a> <a href="/index.html">self</a> <a href="/page?id=1">1</a> <a href="/page?id=2">2</a>`
+	srv := newSite(t, map[string]string{
+		"/":           index,
+		"/index.html": index,
+		"/sub/":       `<a href="/">home</a>`,
+		"/page":       `<a href="/sub/">sub</a>`,
+	})
+	dir := t.TempDir()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	var log strings.Builder
+	w := NewWget()
+	w.Log = &log
+	if err := w.Init([]string{"-r", "-k", "-P", dir, srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if status := w.Run(); status != 0 {
+		t.Errorf("status: %v", status)
+	}
+
+	want := map[string]string{
+		"index.html":           `<a href="sub/index.html">sub</a> <a href="index.html">self</a> <a href="page/index.html%3Fid=1">1</a> <a href="page/index.html%3Fid=2">2</a>`,
+		"sub/index.html":       `<a href="../index.html">home</a>`,
+		"page/index.html?id=1": `<a href="../sub/index.html">sub</a>`,
+		"page/index.html?id=2": `<a href="../sub/index.html">sub</a>`,
+	}
+	for name, page := range want {
+		path := filepath.Join(dir, host, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != page {
+			t.Errorf("%v: got: %q, want: %q", name, data, page)
+		}
+		if n := strings.Count(log.String(), "convert: ["+path+"]"); n != 1 {
+			t.Errorf("%v converted %v times", name, n)
+		}
+	}
+}
+
+func TestPageRequisites(t *testing.T) {
+	cdn := newSite(t, map[string]string{"/lib.js": "js", "/other.js": "js"})
+	cdnHost := strings.TrimPrefix(cdn.URL, "http://")
+	srv := newSite(t, map[string]string{
+		"/": `<link rel="stylesheet" href="/s.css"><link rel="alternate" href="/feed">` +
+			`<style>@import "/i.css"; b{background:url(bg.png)}</style>` +
+			`<script src="` + cdn.URL + `/lib.js"></script><script src="http://example.invalid/x.js"></script>` +
+			`<img src="a.png" srcset="a2.png 2x"><picture><source srcset="b.png"></picture>` +
+			`<p style="background:url('/c.png')"><a href="/page">page</a></p>`,
+		"/s.css":  `@font-face{src:url("fonts/f.woff")}`,
+		"/i.css":  `i{}`,
+		"/page":   `<script src="` + cdn.URL + `/other.js"></script>`,
+		"/bg.png": "png", "/a.png": "png", "/a2.png": "png", "/b.png": "png", "/c.png": "png",
+		"/fonts/f.woff": "woff",
+		"/feed":         "feed",
+	})
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	testCases := []struct {
+		desc string
+		args []string
+		want []string
+	}{
+		{
+			desc: "single page",
+			args: []string{"-p", "-D", cdnHost, srv.URL + "/"},
+			want: []string{
+				cdnHost + "/lib.js", host + "/a.png", host + "/a2.png", host + "/b.png", host + "/bg.png", host + "/c.png",
+				host + "/fonts/f.woff", host + "/i.css", host + "/index.html", host + "/s.css",
+			},
+		},
+		{
+			desc: "no cdn",
+			args: []string{"--page-requisites", srv.URL + "/page"},
+			want: []string{host + "/page/index.html"},
+		},
+		{
+			desc: "at max depth",
+			args: []string{"-r", "-l", "1", "-p", "-D", cdnHost, srv.URL + "/"},
+			want: []string{
+				cdnHost + "/lib.js", cdnHost + "/other.js", host + "/a.png", host + "/a2.png", host + "/b.png", host + "/bg.png",
+				host + "/c.png", host + "/fonts/f.woff", host + "/i.css", host + "/index.html", host + "/page/index.html", host + "/s.css",
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			w := NewWget()
+			w.Log = io.Discard
+			if err := w.Init(append([]string{"-P", dir}, tC.args...)); err != nil {
+				t.Fatal(err)
+			}
+			if status := w.Run(); status != 0 {
+				t.Errorf("status: %v", status)
+			}
+			slices.Sort(tC.want)
+			if got := savedFiles(t, dir); !slices.Equal(got, tC.want) {
+				t.Errorf("files: %v, want: %v", got, tC.want)
+			}
+		})
+	}
+}